@@ -1,11 +1,32 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
+	"os"
+	"time"
 )
 
-func tournament(dir string) {
+//ArenaConfig is how tournament games are played. Each player searches within Limits, which can give both players a
+//clock, with a transposition table of HashMB megabytes. LogSearch prints every iteration of every search, and games
+//are saved to GamesDir. Players pick among their best MultiPV moves at Temperature, so games between the same
+//policies differ
+type ArenaConfig struct {
+	Limits      SearchLimits
+	HashMB      int
+	LogSearch   bool
+	Tablebase   Tablebase
+	Book        *PolyglotBook
+	GamesDir    string
+	MultiPV     int
+	Temperature float64
+}
+
+//tournament plays every policy in dir against every other and saves the games, for building opening books from.
+//Cancelling ctx ends the tournament after saving the games already finished
+func tournament(ctx context.Context, dir string, config ArenaConfig) {
 	files, _ := ioutil.ReadDir(dir)
 
 	scores := map[string]int{}
@@ -13,19 +34,26 @@ func tournament(dir string) {
 		scores[file.Name()] = 0
 	}
 
+	records := []GameRecord{}
 	for _, file := range files {
 		for _, otherFile := range files {
-			if file.Name() != otherFile.Name() {
-				playMatchWithResult(file.Name(), otherFile.Name(), dir, scores)
+			if file.Name() != otherFile.Name() && ctx.Err() == nil {
+				record := playMatchWithResult(ctx, file.Name(), otherFile.Name(), dir, config, scores)
+				if record.Result != Undecided {
+					records = append(records, record)
+				}
 			}
 		}
 	}
 
 	print(scores)
+	_ = os.MkdirAll(config.GamesDir, 0755)
+	writeGameRecords(records, time.Now().Format("20060102150405"), config.GamesDir)
 }
 
-func playMatchWithResult(file1, file2, dir string, scores map[string]int) {
-	result := playMatch(file1, file2, dir)
+func playMatchWithResult(ctx context.Context, file1, file2, dir string, config ArenaConfig, scores map[string]int) GameRecord {
+	record := playMatch(ctx, file1, file2, dir, config)
+	result := record.Result
 	print(result)
 	if result == WhiteWon {
 		scores[file1] = scores[file1] + 2
@@ -37,34 +65,187 @@ func playMatchWithResult(file1, file2, dir string, scores map[string]int) {
 		scores[file1] = scores[file1] + 1
 		scores[file2] = scores[file2] + 1
 	}
+	return record
+}
+
+//Player is one side of an arena game: a searcher, the evaluator it searches with and the limits of its clock
+type Player struct {
+	name      string
+	searcher  Searcher
+	evaluator Evaluator
+	limits    SearchLimits
+}
+
+//newPlayer builds the player the policy in file describes. It searches within the arena's limits unless the policy
+//has its own
+func newPlayer(file, dir string, config ArenaConfig) (Player, error) {
+	policy := readConfigJson(file, dir)
+	heuristicConfig := policy.HeauristicConfig.unmarshalJson()
+	evaluator, err := newEvaluator(policy.Player.Evaluator, &heuristicConfig)
+	if err != nil {
+		return Player{}, fmt.Errorf("%s: %s", file, err)
+	}
+	limits := config.Limits
+	if policy.Player.Limits != nil {
+		limits = *policy.Player.Limits
+	}
+
+	var searcher Searcher
+	switch policy.Player.Searcher {
+	case "", alphaBetaSearch:
+		alphaBeta := newAlphaBetaSearcher(evaluator.evaluate, config.Tablebase, newTranspositionTable(config.HashMB))
+		alphaBeta.options.MultiPV = config.MultiPV
+		if config.LogSearch {
+			alphaBeta.onInfo = logSearch(file)
+		}
+		searcher = alphaBeta
+	case mctsSearch:
+		treeSearcher := newMCTSSearcher(evaluator.evaluate, nil, config.Tablebase)
+		treeSearcher.options.MultiPV = config.MultiPV
+		if config.LogSearch {
+			treeSearcher.onInfo = logSearch(file)
+		}
+		searcher = treeSearcher
+	default:
+		return Player{}, fmt.Errorf("%s: unknown searcher %s", file, policy.Player.Searcher)
+	}
+	return Player{file, searcher, evaluator, limits}, nil
+}
+
+//playMatch plays file1's policy as white against file2's. A game interrupted by cancelling ctx, or between policies
+//that cannot be played, is Undecided
+func playMatch(ctx context.Context, file1, file2, dir string, config ArenaConfig) GameRecord {
+	record := GameRecord{file1, file2, Stalemate, []string{}}
+	white, err := newPlayer(file1, dir, config)
+	if err == nil {
+		var black Player
+		black, err = newPlayer(file2, dir, config)
+		if err == nil {
+			return playGame(ctx, white, black, config, record)
+		}
+	}
+	print(err.Error() + "\n")
+	record.Result = Undecided
+	return record
 }
 
-func playMatch(file1, file2, dir string) WinState {
-	config1 := readConfigJson(file1, dir)
-	config2 := readConfigJson(file2, dir)
+//playGame plays white against black, filling in record with the moves and result
+func playGame(ctx context.Context, white, black Player, config ArenaConfig, record GameRecord) GameRecord {
+	tablebase, book := config.Tablebase, config.Book
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
 	state := NewBoard()
+	whiteClock, blackClock := white.limits, black.limits
 
 	gameString := "\n----------\n"
 
 	for i := 0; i < 100; i++ {
-		tree := createRoot(4, White, &state, Player1, config1, config2)
-		nextMove := tree.nextMove
+		started := time.Now()
+		nextMove := chooseMove(ctx, &state, white.searcher, whiteClock, book, random, config.Temperature)
+		if ctx.Err() != nil {
+			print(gameString + "\ninterrupted\n")
+			record.Result = Undecided
+			return record
+		}
+		if nextMove == nil {
+			print(gameString)
+			record.Result = noMoveResult(state)
+			return record
+		}
+		if !whiteClock.spend(time.Since(started), white.limits) {
+			print(gameString + "\nwhite ran out of time\n")
+			record.Result = BlackWon
+			return record
+		}
 		print(fmt.Sprintf("%d. %s ", i+1, nextMove.lastMoveString))
 		gameString += fmt.Sprintf("%d. %s ", i+1, nextMove.lastMoveString)
+		record.Moves = append(record.Moves, moveName(nextMove))
 		state = *nextMove
+		if result, ok := adjudicate(state, tablebase); ok {
+			print(gameString)
+			record.Result = result
+			return record
+		}
 
-		tree = createRoot(4, Black, &state, Player2, config1, config2)
-		nextMove = tree.nextMove
+		started = time.Now()
+		nextMove = chooseMove(ctx, &state, black.searcher, blackClock, book, random, config.Temperature)
+		if ctx.Err() != nil {
+			print(gameString + "\ninterrupted\n")
+			record.Result = Undecided
+			return record
+		}
+		if nextMove == nil {
+			print(gameString)
+			record.Result = noMoveResult(state)
+			return record
+		}
+		if !blackClock.spend(time.Since(started), black.limits) {
+			print(gameString + "\nblack ran out of time\n")
+			record.Result = WhiteWon
+			return record
+		}
 		print(fmt.Sprint(nextMove.lastMoveString))
 		gameString += fmt.Sprint(nextMove.lastMoveString)
+		record.Moves = append(record.Moves, moveName(nextMove))
 		state = *nextMove
 
 		if state.winner != Undecided {
 			print(gameString)
-			return state.winner
+			record.Result = state.winner
+			return record
+		}
+		if result, ok := adjudicate(state, tablebase); ok {
+			print(gameString)
+			record.Result = result
+			return record
 		}
 
 	}
 	print(gameString)
-	return Stalemate
+	return record
+}
+
+//logSearch prints each iteration of a player's search
+func logSearch(player string) func(SearchResult) {
+	return func(result SearchResult) {
+		print(fmt.Sprintf("\n%s: %s", player, result))
+	}
+}
+
+//chooseMove plays a book move when the book has one, and otherwise searches within limits and picks one of the best
+//moves at temperature
+func chooseMove(ctx context.Context, state *Board, searcher Searcher, limits SearchLimits, book *PolyglotBook, random *rand.Rand, temperature float64) *Board {
+	if move := book.move(*state); move != nil {
+		return move
+	}
+	return searcher.think(ctx, state, limits).pickMove(random, temperature)
+}
+
+//noMoveResult is the result of a game where the side to move has no moves
+func noMoveResult(state Board) WinState {
+	if !state.isChecked() {
+		return Stalemate
+	}
+	if state.colourToMove == White {
+		return BlackWon
+	}
+	return WhiteWon
+}
+
+//adjudicate ends a game once it reaches a tablebase position, since its result is already known
+func adjudicate(state Board, tablebase Tablebase) (WinState, bool) {
+	if state.winner != Undecided {
+		return state.winner, true
+	}
+	wdl, ok := probeTablebase(tablebase, state)
+	if !ok {
+		return Undecided, false
+	}
+	value := tablebaseValue(wdl, state.colourToMove)
+	if value > 0 {
+		return WhiteWon, true
+	}
+	if value < 0 {
+		return BlackWon, true
+	}
+	return Stalemate, true
 }
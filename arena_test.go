@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestNewPlayer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir += "/"
+	config := randomConfig().marshalJson()
+	writeConfig(Policy{config, PlayerConfig{}}, "default", dir)
+	writeConfig(Policy{config, PlayerConfig{mctsSearch, materialEvaluation, &SearchLimits{Nodes: 10}}}, "mcts", dir)
+	writeConfig(Policy{config, PlayerConfig{"random", "", nil}}, "unknown", dir)
+
+	arena := ArenaConfig{Limits: SearchLimits{Depth: 1}}
+	player, err := newPlayer("default.json", dir, arena)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := player.searcher.(*AlphaBetaSearcher); !ok || player.limits != arena.Limits {
+		t.Errorf("policies without a player should search with alpha-beta within the arena's limits")
+	}
+	heuristic, ok := player.evaluator.(HeuristicEvaluator)
+	board := NewBoard()
+	if !ok || heuristic.evaluate(board) != generalHeuristic(&board, heuristic.config) {
+		t.Errorf("policies without a player should evaluate with their own heuristic")
+	}
+
+	opponent, err := newPlayer("mcts.json", dir, arena)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := opponent.searcher.(*MCTSSearcher); !ok || opponent.limits.Nodes != 10 {
+		t.Errorf("expected a Monte Carlo player with its own limits")
+	}
+	if _, ok := opponent.evaluator.(MaterialEvaluator); !ok {
+		t.Errorf("expected a material evaluator")
+	}
+
+	if _, err := newPlayer("unknown.json", dir, arena); err == nil {
+		t.Errorf("expected an error for an unknown searcher")
+	}
+
+	//any searcher can play any other. Material is quicker to search with than a random heuristic
+	alphaBeta := Player{"material", newAlphaBetaSearcher(MaterialEvaluator{}.evaluate, nil, nil), MaterialEvaluator{}, arena.Limits}
+	record := playGame(context.Background(), opponent, alphaBeta, arena, GameRecord{"mcts", "material", Stalemate, []string{}})
+	if len(record.Moves) == 0 || record.Result == Undecided {
+		t.Errorf("expected a finished game but got %d moves and %s", len(record.Moves), record.Result)
+	}
+	replay := NewBoard()
+	for _, move := range record.Moves {
+		next := replay.playMoveName(move)
+		if next == nil {
+			t.Fatalf("%s is not a legal move", move)
+		}
+		replay = *next
+	}
+}
@@ -31,6 +31,14 @@ const (
 	Undecided WinState = "Undecided"
 )
 
+//opposite returns the other colour
+func (colour Colour) opposite() Colour {
+	if colour == White {
+		return Black
+	}
+	return White
+}
+
 //Board is a representation of a chess board
 type Board struct {
 	pieces                  []*Piece
@@ -52,10 +60,18 @@ type Board struct {
 	fiftyMoveCounter        int
 	pastStates              []string
 	threeMoveCount          int
+	variant                 Variant
+	whitePocket             map[string]int
+	blackPocket             map[string]int
+	promoted                [8][8]bool
 }
 
 //BoardInitialise initialises a Board
 func BoardInitialise(pieces []*Piece, enPassantRank int, colourToMove Colour, canBlackKingSideCastle, canBlackQueenSideCastle, canWhiteKingSideCastle, canWhiteQueenSideCastle bool, lastMoveString string, moveCounter, fiftyMoveCounter int) Board {
+	return initialiseBoard(Standard, pieces, map[string]int{}, map[string]int{}, [8][8]bool{}, enPassantRank, colourToMove, canBlackKingSideCastle, canBlackQueenSideCastle, canWhiteKingSideCastle, canWhiteQueenSideCastle, lastMoveString, moveCounter, fiftyMoveCounter)
+}
+
+func initialiseBoard(variant Variant, pieces []*Piece, whitePocket, blackPocket map[string]int, promoted [8][8]bool, enPassantRank int, colourToMove Colour, canBlackKingSideCastle, canBlackQueenSideCastle, canWhiteKingSideCastle, canWhiteQueenSideCastle bool, lastMoveString string, moveCounter, fiftyMoveCounter int) Board {
 	squares := make([][]*Piece, 8)
 	pastStates := []string{}
 	threeMoveCount := 0
@@ -92,6 +108,10 @@ func BoardInitialise(pieces []*Piece, enPassantRank int, colourToMove Colour, ca
 		fiftyMoveCounter,
 		pastStates,
 		threeMoveCount,
+		variant,
+		whitePocket,
+		blackPocket,
+		promoted,
 	}
 
 	returnState.coveredSquaresWhite = returnState.getCoveredSquares(White)
@@ -106,6 +126,10 @@ func BoardInitialise(pieces []*Piece, enPassantRank int, colourToMove Colour, ca
 
 //NewBoard sets up a board in its inital state
 func NewBoard() Board {
+	return BoardInitialise(initialPieces(), -1, White, true, true, true, true, "", 1, 0)
+}
+
+func initialPieces() []*Piece {
 	pieces := []*Piece{
 		{rook, White, Vector{X: 0, Y: 0}},
 		{rook, White, Vector{X: 7, Y: 0}},
@@ -130,7 +154,7 @@ func NewBoard() Board {
 		pieces = append(pieces, &Piece{pawn, Black, Vector{X: i, Y: 6}})
 	}
 
-	return BoardInitialise(pieces, -1, White, true, true, true, true, "", 1, 0)
+	return pieces
 }
 
 func (boardState Board) getCoveredSquares(colour Colour) [][]bool {
@@ -167,6 +191,14 @@ func (boardState Board) getIsBlackChecked() bool {
 	return false
 }
 
+//isChecked is whether the colour to move is in check
+func (boardState Board) isChecked() bool {
+	if boardState.colourToMove == White {
+		return boardState.isWhiteChecked
+	}
+	return boardState.isBlackChecked
+}
+
 func (boardState Board) isWhiteCheckmated() bool {
 	return boardState.isWhiteChecked && !boardState.hasPossibleBoardMoves()
 }
@@ -181,6 +213,10 @@ func (boardState Board) isStalemate() bool {
 }
 
 func (boardState Board) checkSufficientMaterial(colour Colour) bool {
+	if boardState.variant == Crazyhouse {
+		//captured pieces can always be dropped back in
+		return true
+	}
 	nCount := 0
 	bCount := 0
 	hasRook := false
@@ -260,6 +296,9 @@ func (boardState Board) SimpleString() string {
 	if !boardState.canWhiteKingSideCastle && !boardState.canWhiteQueenSideCastle && !boardState.canBlackKingSideCastle && !boardState.canBlackQueenSideCastle {
 		out += "-"
 	}
+	if boardState.variant == Crazyhouse {
+		out += " [" + boardState.pocketString() + "]"
+	}
 	return out
 }
 
@@ -271,6 +310,35 @@ func (boardState Board) ToString() string {
 	return out
 }
 
+//Mirror flips the board between the first and eighth ranks and swaps the colours of every piece, so that
+//castling rights, en passant, pockets and the side to move all change sides with them
+func (boardState Board) Mirror() Board {
+	pieces := []*Piece{}
+	promoted := [8][8]bool{}
+	for _, piece := range boardState.pieces {
+		mirrored := piece.position.mirror()
+		pieces = append(pieces, &Piece{piece.pieceType, piece.colour.opposite(), mirrored})
+		promoted[mirrored.X][mirrored.Y] = boardState.promoted[piece.position.X][piece.position.Y]
+	}
+
+	return initialiseBoard(
+		boardState.variant,
+		pieces,
+		copyPocket(boardState.blackPocket),
+		copyPocket(boardState.whitePocket),
+		promoted,
+		boardState.enPassantRank,
+		boardState.colourToMove.opposite(),
+		boardState.canWhiteKingSideCastle,
+		boardState.canWhiteQueenSideCastle,
+		boardState.canBlackKingSideCastle,
+		boardState.canBlackQueenSideCastle,
+		"",
+		boardState.moveCounter,
+		boardState.fiftyMoveCounter,
+	)
+}
+
 func (boardState Board) clone() Board {
 	pieceClones := []*Piece{}
 	squareClones := make([][]*Piece, 8)
@@ -304,6 +372,10 @@ func (boardState Board) clone() Board {
 		boardState.fiftyMoveCounter,
 		boardState.pastStates,
 		boardState.threeMoveCount,
+		boardState.variant,
+		copyPocket(boardState.whitePocket),
+		copyPocket(boardState.blackPocket),
+		boardState.promoted,
 	}
 }
 
@@ -316,7 +388,9 @@ func (boardState Board) MakeMove(piece *Piece, move Vector, promotion *PieceType
 	nextState.fiftyMoveCounter++
 
 	//remove taken piece
-	if nextState.getSquare(move.X, move.Y) != nil {
+	takenPiece := nextState.getSquare(move.X, move.Y)
+	if takenPiece != nil {
+		nextState.addToPocket(piece.colour, takenPiece.pieceType.sign, boardState.promoted[move.X][move.Y])
 		for i, piece2 := range nextState.pieces {
 			if piece2.position.X == move.X && piece2.position.Y == move.Y {
 				nextState.pieces[i] = nextState.pieces[len(nextState.pieces)-1]
@@ -340,15 +414,15 @@ func (boardState Board) MakeMove(piece *Piece, move Vector, promotion *PieceType
 	nextState.squares[move.X][move.Y] = pieceDouble
 	nextState.squares[piece.position.X][piece.position.Y] = nil
 	pieceDouble.position = move
+	nextState.promoted[move.X][move.Y] = boardState.promoted[piece.position.X][piece.position.Y]
+	nextState.promoted[piece.position.X][piece.position.Y] = false
 
 	//remove en passant taken piece
-	if pieceDouble.pieceType.sign == "P" && nextState.enPassantRank == move.X {
-		dir := 1
-		if piece.colour == Black {
-			dir = -1
-		}
+	if pieceDouble.pieceType.sign == "P" && takenPiece == nil && move.X != piece.position.X {
 		for i, piece2 := range nextState.pieces {
-			if piece2.position.X == move.X && piece2.position.Y == move.Y-dir {
+			if piece2.position.X == move.X && piece2.position.Y == piece.position.Y {
+				nextState.addToPocket(piece.colour, piece2.pieceType.sign, false)
+				nextState.squares[move.X][piece.position.Y] = nil
 				nextState.pieces[i] = nextState.pieces[len(nextState.pieces)-1]
 				nextState.pieces = nextState.pieces[:len(nextState.pieces)-1]
 				break
@@ -364,26 +438,27 @@ func (boardState Board) MakeMove(piece *Piece, move Vector, promotion *PieceType
 		}
 		//promote pawn
 		pieceDouble.pieceType = *promotion
+		nextState.promoted[move.X][move.Y] = true
 		nextState.lastMoveString += "=" + strings.ToUpper(promotion.sign)
 	}
 
 	//castling
 	if pieceDouble.pieceType.sign == "K" {
-		if move.X == 2 {
+		if move.X == 2 && piece.position.X == 4 {
 			if pieceDouble.colour == White && nextState.canWhiteQueenSideCastle {
-				nextState.getSquare(0, 0).position.X = 3
+				nextState.moveCastlingRook(Vector{X: 0, Y: 0}, Vector{X: 3, Y: 0})
 			}
 			if pieceDouble.colour == Black && nextState.canBlackQueenSideCastle {
-				nextState.getSquare(0, 7).position.X = 3
+				nextState.moveCastlingRook(Vector{X: 0, Y: 7}, Vector{X: 3, Y: 7})
 			}
 			nextState.lastMoveString = "O-O-O"
 		}
-		if move.X == 6 {
+		if move.X == 6 && piece.position.X == 4 {
 			if pieceDouble.colour == White && nextState.canWhiteKingSideCastle {
-				nextState.getSquare(7, 0).position.X = 5
+				nextState.moveCastlingRook(Vector{X: 7, Y: 0}, Vector{X: 5, Y: 0})
 			}
 			if pieceDouble.colour == Black && nextState.canBlackKingSideCastle {
-				nextState.getSquare(7, 7).position.X = 5
+				nextState.moveCastlingRook(Vector{X: 7, Y: 7}, Vector{X: 5, Y: 7})
 			}
 			nextState.lastMoveString = "O-O"
 		}
@@ -398,14 +473,6 @@ func (boardState Board) MakeMove(piece *Piece, move Vector, promotion *PieceType
 		}
 	}
 
-	//set new covered squares
-	nextState.coveredSquaresBlack = nextState.getCoveredSquares(Black)
-	nextState.coveredSquaresWhite = nextState.getCoveredSquares(White)
-
-	//is checked
-	nextState.isBlackChecked = nextState.getIsBlackChecked()
-	nextState.isWhiteChecked = nextState.getIsWhiteChecked()
-
 	//update castling state
 	if pieceDouble.pieceType.sign == "K" {
 		if pieceDouble.colour == White {
@@ -448,6 +515,27 @@ func (boardState Board) MakeMove(piece *Piece, move Vector, promotion *PieceType
 		nextState.canBlackKingSideCastle = false
 	}
 
+	nextState.finishMove(boardState)
+	return nextState
+}
+
+func (nextState *Board) moveCastlingRook(from Vector, to Vector) {
+	rookPiece := nextState.getSquare(from.X, from.Y)
+	rookPiece.position = to
+	nextState.squares[to.X][to.Y] = rookPiece
+	nextState.squares[from.X][from.Y] = nil
+}
+
+//finishMove recalculates the derived state of a board after a move or drop has been made on it
+func (nextState *Board) finishMove(boardState Board) {
+	//set new covered squares
+	nextState.coveredSquaresBlack = nextState.getCoveredSquares(Black)
+	nextState.coveredSquaresWhite = nextState.getCoveredSquares(White)
+
+	//is checked
+	nextState.isBlackChecked = nextState.getIsBlackChecked()
+	nextState.isWhiteChecked = nextState.getIsWhiteChecked()
+
 	if nextState.colourToMove == White {
 		nextState.colourToMove = Black
 		nextState.moveCounter++
@@ -455,7 +543,10 @@ func (boardState Board) MakeMove(piece *Piece, move Vector, promotion *PieceType
 		nextState.colourToMove = White
 	}
 
-	nextState.pastStates = append(boardState.pastStates, boardState.SimpleString())
+	//the capacity is capped so that every move from a board appends to its own copy of the history, rather than all
+	//of them sharing one array
+	pastStates := boardState.pastStates[:len(boardState.pastStates):len(boardState.pastStates)]
+	nextState.pastStates = append(pastStates, boardState.SimpleString())
 	// if piece.pieceType.sign == "P" {
 	// 	nextState.pastStates = []string{}
 	// }
@@ -474,8 +565,6 @@ func (boardState Board) MakeMove(piece *Piece, move Vector, promotion *PieceType
 	if (!boardState.checkSufficientMaterial(Black) && !boardState.checkSufficientMaterial(White)) || boardState.fiftyMoveCounter >= 100 || boardState.threeMoveCount >= 2 {
 		nextState.winner = Stalemate
 	}
-
-	return nextState
 }
 
 func (boardState Board) getPossibleMoves() []*Board {
@@ -485,21 +574,12 @@ func (boardState Board) getPossibleMoves() []*Board {
 			moves := piece.getPossibleMoves(boardState)
 			for _, move := range moves {
 				if piece.pieceType.sign == "P" && (move.Y == 0 || move.Y == 7) {
-					nextState := boardState.MakeMove(piece, move, &knight)
-					if nextState.verifyBoardState() {
-						returnStates = append(returnStates, &nextState)
-					}
-					nextState = boardState.MakeMove(piece, move, &bishop)
-					if nextState.verifyBoardState() {
-						returnStates = append(returnStates, &nextState)
-					}
-					nextState = boardState.MakeMove(piece, move, &rook)
-					if nextState.verifyBoardState() {
-						returnStates = append(returnStates, &nextState)
-					}
-					nextState = boardState.MakeMove(piece, move, &queen)
-					if nextState.verifyBoardState() {
-						returnStates = append(returnStates, &nextState)
+					//each promotion needs its own board, or every child would point at the last one
+					for _, promotion := range []*PieceType{&knight, &bishop, &rook, &queen} {
+						nextState := boardState.MakeMove(piece, move, promotion)
+						if nextState.verifyBoardState() {
+							returnStates = append(returnStates, &nextState)
+						}
 					}
 				} else {
 					nextState := boardState.MakeMove(piece, move, nil)
@@ -510,6 +590,9 @@ func (boardState Board) getPossibleMoves() []*Board {
 			}
 		}
 	}
+	if boardState.variant == Crazyhouse {
+		returnStates = append(returnStates, boardState.getPossibleDrops()...)
+	}
 	return returnStates
 }
 
@@ -521,6 +604,9 @@ func (boardState Board) hasPossibleBoardMoves() bool {
 			}
 		}
 	}
+	if boardState.variant == Crazyhouse {
+		return boardState.hasPossibleDrops()
+	}
 	return false
 }
 
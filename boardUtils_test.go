@@ -87,3 +87,34 @@ func TestCanDetermineStalemate(t *testing.T) {
 		t.Errorf("should be stalemate for black to move but isnt")
 	}
 }
+
+func TestFENRoundTrip(t *testing.T) {
+	for _, fen := range []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR b Kq e3 0 3",
+		"r1bqkb1r/pppp1ppp/2n2n2/4p3/4P3/2N2N2/PPPP1PPP/R1BQKB1R[Pp] w KQkq - 4 4",
+		"4Q~k2/8/8/8/8/8/8/4K3[QRbn] b - - 0 40",
+	} {
+		state, err := BoardFromFEN(fen)
+		if err != nil {
+			t.Errorf("could not read %s: %v", fen, err)
+			continue
+		}
+		if state.ToFEN() != fen {
+			t.Errorf("expected %s but got %s", fen, state.ToFEN())
+		}
+	}
+}
+
+func TestFENNinthRankPocket(t *testing.T) {
+	state, err := BoardFromFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR/Nn w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.variant != Crazyhouse || state.whitePocket["N"] != 1 || state.blackPocket["N"] != 1 {
+		t.Errorf("expected crazyhouse board with a knight in each pocket")
+	}
+	if NewBoard().ToFEN() != "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1" {
+		t.Errorf("unexpected initial FEN %s", NewBoard().ToFEN())
+	}
+}
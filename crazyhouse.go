@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+//Variant is the rule set a board is played under
+type Variant string
+
+const (
+	//Standard chess
+	Standard Variant = "Standard"
+	//Crazyhouse chess, where captured pieces can be dropped back onto the board
+	Crazyhouse Variant = "Crazyhouse"
+)
+
+//pocketOrder is the order pieces are listed in when a pocket is written out
+var pocketOrder = []string{"Q", "R", "B", "N", "P"}
+
+//NewCrazyhouseBoard sets up a Crazyhouse board in its inital state with empty pockets
+func NewCrazyhouseBoard() Board {
+	return CrazyhouseBoardInitialise(initialPieces(), map[string]int{}, map[string]int{}, [8][8]bool{}, -1, White, true, true, true, true, "", 1, 0)
+}
+
+//CrazyhouseBoardInitialise initialises a Crazyhouse Board. promoted marks the squares holding promoted pieces, which revert to pawns when captured
+func CrazyhouseBoardInitialise(pieces []*Piece, whitePocket, blackPocket map[string]int, promoted [8][8]bool, enPassantRank int, colourToMove Colour, canBlackKingSideCastle, canBlackQueenSideCastle, canWhiteKingSideCastle, canWhiteQueenSideCastle bool, lastMoveString string, moveCounter, fiftyMoveCounter int) Board {
+	return initialiseBoard(Crazyhouse, pieces, whitePocket, blackPocket, promoted, enPassantRank, colourToMove, canBlackKingSideCastle, canBlackQueenSideCastle, canWhiteKingSideCastle, canWhiteQueenSideCastle, lastMoveString, moveCounter, fiftyMoveCounter)
+}
+
+func copyPocket(pocket map[string]int) map[string]int {
+	pocketClone := map[string]int{}
+	for sign, count := range pocket {
+		pocketClone[sign] = count
+	}
+	return pocketClone
+}
+
+func (boardState Board) pocket(colour Colour) map[string]int {
+	if colour == White {
+		return boardState.whitePocket
+	}
+	return boardState.blackPocket
+}
+
+func (boardState *Board) addToPocket(colour Colour, sign string, wasPromoted bool) {
+	if boardState.variant != Crazyhouse {
+		return
+	}
+	if wasPromoted {
+		sign = "P"
+	}
+	boardState.pocket(colour)[sign]++
+}
+
+//pocketString writes both pockets in FEN style, white pieces in upper case and black pieces in lower case
+func (boardState Board) pocketString() string {
+	out := ""
+	for _, sign := range pocketOrder {
+		out += strings.Repeat(sign, boardState.whitePocket[sign])
+	}
+	for _, sign := range pocketOrder {
+		out += strings.Repeat(strings.ToLower(sign), boardState.blackPocket[sign])
+	}
+	return out
+}
+
+func canDropOn(sign string, square Vector) bool {
+	return !(sign == "P" && (square.Y == 0 || square.Y == 7))
+}
+
+//MakeDrop exports a board with a piece from the pocket of the colour to move dropped onto an empty square
+func (boardState Board) MakeDrop(pieceType *PieceType, square Vector) Board {
+	nextState := boardState.clone()
+	dropped := &Piece{*pieceType, boardState.colourToMove, square}
+
+	nextState.pieces = append(nextState.pieces, dropped)
+	nextState.squares[square.X][square.Y] = dropped
+	nextState.pocket(boardState.colourToMove)[pieceType.sign]--
+	nextState.lastMoveString = strings.ToUpper(pieceType.sign) + "@" + square.boardPosition() + " "
+	nextState.enPassantRank = -1
+
+	nextState.fiftyMoveCounter++
+	if pieceType.sign == "P" {
+		nextState.fiftyMoveCounter = 0
+	}
+
+	nextState.finishMove(boardState)
+	return nextState
+}
+
+func (boardState Board) getPossibleDrops() []*Board {
+	returnStates := []*Board{}
+	pocket := boardState.pocket(boardState.colourToMove)
+	for _, sign := range pocketOrder {
+		if pocket[sign] == 0 {
+			continue
+		}
+		for x := 0; x < 8; x++ {
+			for y := 0; y < 8; y++ {
+				square := Vector{X: x, Y: y}
+				if boardState.getSquare(x, y) != nil || !canDropOn(sign, square) {
+					continue
+				}
+				nextState := boardState.MakeDrop(pieceTypes[sign], square)
+				if nextState.verifyBoardState() {
+					returnStates = append(returnStates, &nextState)
+				}
+			}
+		}
+	}
+	return returnStates
+}
+
+func (boardState Board) hasPossibleDrops() bool {
+	pocket := boardState.pocket(boardState.colourToMove)
+	for _, sign := range pocketOrder {
+		if pocket[sign] == 0 {
+			continue
+		}
+		for x := 0; x < 8; x++ {
+			for y := 0; y < 8; y++ {
+				square := Vector{X: x, Y: y}
+				if boardState.getSquare(x, y) != nil || !canDropOn(sign, square) {
+					continue
+				}
+				if nextState := boardState.MakeDrop(pieceTypes[sign], square); nextState.verifyBoardState() {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+//parsePocket reads a FEN pocket such as "QNnpp" into white and black pockets
+func parsePocket(pocket string) (map[string]int, map[string]int, error) {
+	whitePocket := map[string]int{}
+	blackPocket := map[string]int{}
+	for _, char := range pocket {
+		sign := strings.ToUpper(string(char))
+		if _, ok := pieceTypes[sign]; !ok || sign == "K" {
+			return nil, nil, fmt.Errorf("invalid pocket piece %q", char)
+		}
+		if sign == string(char) {
+			whitePocket[sign]++
+		} else {
+			blackPocket[sign]++
+		}
+	}
+	return whitePocket, blackPocket, nil
+}
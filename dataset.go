@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+//LabelledPosition is a position with a value from white's point of view, used to train and score evaluators
+type LabelledPosition struct {
+	FEN   string  `json:"fen"`
+	Value float64 `json:"value"`
+}
+
+//labelPositions values each board with evaluate. With mirror set, the colour-mirrored copy of each board is added
+//with its value negated, doubling the dataset without any further evaluation
+func labelPositions(boards []Board, evaluate func(Board) float64, mirror bool) []LabelledPosition {
+	positions := []LabelledPosition{}
+	for _, board := range boards {
+		positions = append(positions, LabelledPosition{FEN: board.ToFEN(), Value: evaluate(board)})
+	}
+	if mirror {
+		return mirrorAugment(positions)
+	}
+	return positions
+}
+
+//mirrorAugment returns the dataset followed by the colour-mirrored copy of every position, with its value negated.
+//Positions that cannot be read are dropped
+func mirrorAugment(positions []LabelledPosition) []LabelledPosition {
+	augmented := []LabelledPosition{}
+	mirrored := []LabelledPosition{}
+	for _, position := range positions {
+		board, err := BoardFromFEN(position.FEN)
+		if err != nil {
+			print(err.Error() + "\n")
+			continue
+		}
+		augmented = append(augmented, position)
+		mirrored = append(mirrored, LabelledPosition{FEN: board.Mirror().ToFEN(), Value: -position.Value})
+	}
+	return append(augmented, mirrored...)
+}
+
+func writeDataset(positions []LabelledPosition, fileName string, dir string) {
+	fileJson, err := json.Marshal(positions)
+	if err != nil {
+		print("error")
+	}
+	_ = ioutil.WriteFile(dir+fileName+".json", fileJson, 0644)
+}
+
+func readDataset(fileName, dir string) []LabelledPosition {
+	file, err := ioutil.ReadFile(dir + fileName)
+
+	if err != nil {
+		print(err)
+	}
+
+	positions := []LabelledPosition{}
+
+	_ = json.Unmarshal(file, &positions)
+
+	return positions
+}
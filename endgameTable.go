@@ -0,0 +1,554 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+//EndgameTable is an exact distance to mate table for one material set, such as "KQK", built by retrograde analysis.
+//White is the stronger side. Each position is one byte: 0 for a draw or an unused index, the number of plies to mate
+//for a win, or endgameLoss plus the number of plies to being mated for a loss
+type EndgameTable struct {
+	material string
+	values   []byte
+}
+
+//EndgameTables are the tables that have been built or read, by material
+type EndgameTables map[string]*EndgameTable
+
+const endgameLoss = 128
+
+//endgameMaterials are the material sets the generator builds, in an order where promotions only need earlier tables
+var endgameMaterials = []string{"KQK", "KRK", "KBNK", "KPK"}
+
+//isEndgameMaterial is whether the generator can build a material set
+func isEndgameMaterial(material string) bool {
+	for _, known := range endgameMaterials {
+		if material == known {
+			return true
+		}
+	}
+	return false
+}
+
+//drawnMaterial are the material sets where neither side can mate
+var drawnMaterial = map[string]bool{"KK": true, "KBK": true, "KNK": true}
+
+//triangleSquares are where the white king is put in tables without pawns, using the symmetry of the board
+var triangleSquares = []Vector{{0, 0}, {1, 0}, {2, 0}, {3, 0}, {1, 1}, {2, 1}, {3, 1}, {2, 2}, {3, 2}, {3, 3}}
+
+//endgamePieces lists the pieces of a material set in index order: the white king, the black king, then the rest
+func endgamePieces(material string) []Piece {
+	split := strings.LastIndex(material, "K")
+	pieces := []Piece{{king, White, Vector{}}, {king, Black, Vector{}}}
+	for _, sign := range material[1:split] {
+		pieces = append(pieces, Piece{*pieceTypes[string(sign)], White, Vector{}})
+	}
+	for _, sign := range material[split+1:] {
+		pieces = append(pieces, Piece{*pieceTypes[string(sign)], Black, Vector{}})
+	}
+	return pieces
+}
+
+func (table *EndgameTable) hasPawns() bool {
+	return strings.Contains(table.material, "P")
+}
+
+func (table *EndgameTable) kingSquares() int {
+	if table.hasPawns() {
+		return 32
+	}
+	return len(triangleSquares)
+}
+
+func endgameTableSize(material string) int {
+	table := EndgameTable{material, nil}
+	size := 2 * table.kingSquares()
+	for i := 1; i < len(endgamePieces(material)); i++ {
+		size *= 64
+	}
+	return size
+}
+
+//symmetries are the transformations that keep a position the same. Pawns only allow the files to be flipped
+func (table *EndgameTable) symmetries() []func(Vector) Vector {
+	symmetries := []func(Vector) Vector{
+		func(square Vector) Vector { return square },
+		func(square Vector) Vector { return Vector{7 - square.X, square.Y} },
+	}
+	if table.hasPawns() {
+		return symmetries
+	}
+	return append(symmetries,
+		func(square Vector) Vector { return Vector{square.X, 7 - square.Y} },
+		func(square Vector) Vector { return Vector{7 - square.X, 7 - square.Y} },
+		func(square Vector) Vector { return Vector{square.Y, square.X} },
+		func(square Vector) Vector { return Vector{7 - square.Y, square.X} },
+		func(square Vector) Vector { return Vector{square.Y, 7 - square.X} },
+		func(square Vector) Vector { return Vector{7 - square.Y, 7 - square.X} },
+	)
+}
+
+func (table *EndgameTable) kingIndex(square Vector) int {
+	if table.hasPawns() {
+		if square.X > 3 {
+			return -1
+		}
+		return square.Y*4 + square.X
+	}
+	for i, triangleSquare := range triangleSquares {
+		if triangleSquare == square {
+			return i
+		}
+	}
+	return -1
+}
+
+//index gives the position's place in the table. Of the symmetric copies of a position, the one with the lowest index
+//is used, so every position has exactly one index
+func (table *EndgameTable) index(squares []Vector, colourToMove Colour) int {
+	best := -1
+	for _, symmetry := range table.symmetries() {
+		kingIndex := table.kingIndex(symmetry(squares[0]))
+		if kingIndex < 0 {
+			continue
+		}
+		index := 0
+		if colourToMove == Black {
+			index = 1
+		}
+		index = index*table.kingSquares() + kingIndex
+		for _, square := range squares[1:] {
+			square = symmetry(square)
+			index = index*64 + square.Y*8 + square.X
+		}
+		if best < 0 || index < best {
+			best = index
+		}
+	}
+	return best
+}
+
+func (table *EndgameTable) decode(index int) ([]Vector, Colour) {
+	squares := make([]Vector, len(endgamePieces(table.material)))
+	for i := len(squares) - 1; i > 0; i-- {
+		squares[i] = Vector{index % 8, index % 64 / 8}
+		index /= 64
+	}
+	kingIndex := index % table.kingSquares()
+	if table.hasPawns() {
+		squares[0] = Vector{kingIndex % 4, kingIndex / 4}
+	} else {
+		squares[0] = triangleSquares[kingIndex]
+	}
+	if index/table.kingSquares() == 1 {
+		return squares, Black
+	}
+	return squares, White
+}
+
+//placeable is whether the pieces are on different squares, with no pawns on the first or last rank and the kings apart
+func (table *EndgameTable) placeable(squares []Vector) bool {
+	pieces := endgamePieces(table.material)
+	for i, square := range squares {
+		if pieces[i].pieceType.sign == "P" && (square.Y == 0 || square.Y == 7) {
+			return false
+		}
+		for _, other := range squares[:i] {
+			if other == square {
+				return false
+			}
+		}
+	}
+	xDistance := squares[0].X - squares[1].X
+	yDistance := squares[0].Y - squares[1].Y
+	return xDistance < -1 || xDistance > 1 || yDistance < -1 || yDistance > 1
+}
+
+func (table *EndgameTable) board(squares []Vector, colourToMove Colour) Board {
+	pieces := []*Piece{}
+	for i, piece := range endgamePieces(table.material) {
+		pieces = append(pieces, &Piece{piece.pieceType, piece.colour, squares[i]})
+	}
+	return BoardInitialise(pieces, -1, colourToMove, false, false, false, false, "", 1, 0)
+}
+
+//squaresOf finds the squares of the table's pieces on a board with the same material
+func (table *EndgameTable) squaresOf(board Board) []Vector {
+	squares := []Vector{}
+	used := map[*Piece]bool{}
+	for _, wanted := range endgamePieces(table.material) {
+		for _, piece := range board.pieces {
+			if !used[piece] && piece.colour == wanted.colour && piece.pieceType.sign == wanted.pieceType.sign {
+				used[piece] = true
+				squares = append(squares, piece.position)
+				break
+			}
+		}
+	}
+	return squares
+}
+
+func (boardState Board) endgameMaterial() string {
+	return boardState.materialCode(White) + boardState.materialCode(Black)
+}
+
+//ProbeDTM gives the result for the side to move and the number of plies to mate, or false when there is no table
+//for the board
+func (tables EndgameTables) ProbeDTM(board Board) (WDL, int, bool) {
+	if board.variant == Crazyhouse || board.canWhiteKingSideCastle || board.canWhiteQueenSideCastle ||
+		board.canBlackKingSideCastle || board.canBlackQueenSideCastle {
+		return WDLDraw, 0, false
+	}
+	material := board.endgameMaterial()
+	if drawnMaterial[material] {
+		return WDLDraw, 0, true
+	}
+	table, ok := tables[material]
+	if !ok {
+		board = board.Mirror()
+		if table, ok = tables[board.endgameMaterial()]; !ok {
+			return WDLDraw, 0, false
+		}
+	}
+
+	value := int(table.values[table.index(table.squaresOf(board), board.colourToMove)])
+	if value == 0 {
+		return WDLDraw, 0, true
+	}
+	if value >= endgameLoss {
+		return WDLLoss, value - endgameLoss, true
+	}
+	return WDLWin, value, true
+}
+
+//ProbeWDL gives the result for the side to move, or false when there is no table for the board
+func (tables EndgameTables) ProbeWDL(board Board) (WDL, bool) {
+	wdl, _, ok := tables.ProbeDTM(board)
+	return wdl, ok
+}
+
+//unmoves lists the positions the side that has just moved could have come from without a capture or promotion
+func (table *EndgameTable) unmoves(squares []Vector, colourToMove Colour) [][]Vector {
+	occupied := map[Vector]bool{}
+	for _, square := range squares {
+		occupied[square] = true
+	}
+	found := [][]Vector{}
+	unmove := func(i int, from Vector) {
+		previous := append([]Vector{}, squares...)
+		previous[i] = from
+		found = append(found, previous)
+	}
+
+	for i, piece := range endgamePieces(table.material) {
+		if piece.colour == colourToMove {
+			continue
+		}
+		square := squares[i]
+
+		if piece.pieceType.sign == "P" {
+			back := -1
+			if piece.colour == Black {
+				back = 1
+			}
+			from := Vector{square.X, square.Y + back}
+			if from.Y >= 1 && from.Y <= 6 && !occupied[from] {
+				unmove(i, from)
+				doubleFrom := Vector{square.X, square.Y + 2*back}
+				if (piece.colour == White && doubleFrom.Y == 1 || piece.colour == Black && doubleFrom.Y == 6) && !occupied[doubleFrom] {
+					unmove(i, doubleFrom)
+				}
+			}
+			continue
+		}
+
+		for _, direction := range piece.pieceType.moveDirections {
+			for _, sign := range []int{1, -1} {
+				for distance := 1; distance < 8; distance++ {
+					from := square.add(direction.mult(sign * distance))
+					if from.isOutOfBounds() || occupied[from] {
+						break
+					}
+					unmove(i, from)
+				}
+			}
+		}
+		for _, move := range piece.pieceType.otherMoves {
+			if from := square.add(move); !from.isOutOfBounds() && !occupied[from] {
+				unmove(i, from)
+			}
+		}
+	}
+	return found
+}
+
+//generateEndgameTable builds a table by retrograde analysis. Every position's moves are found once with the Board
+//move generator, then results spread backwards from the checkmates through unmoves, one ply at a time. Captures and
+//promotions leave the table and are looked up in tables, which must hold every material set they lead to
+func generateEndgameTable(material string, tables EndgameTables) (*EndgameTable, error) {
+	if !isEndgameMaterial(material) {
+		return nil, fmt.Errorf("cannot build %s, only %s", material, strings.Join(endgameMaterials, ", "))
+	}
+	size := endgameTableSize(material)
+	table := &EndgameTable{material, make([]byte, size)}
+	unused := make([]bool, size)
+	resolved := make([]bool, size)
+	//remaining counts the moves to other positions in the table that are not yet known to be wins for the opponent
+	remaining := make([]int, size)
+	//canLose is false once the side to move has a move leaving the table that does not lose
+	canLose := make([]bool, size)
+	longestExitLoss := make([]int, size)
+	winsAt := [][]int{}
+	lossesAt := [][]int{}
+	addAt := func(levels [][]int, level int, index int) [][]int {
+		for len(levels) <= level {
+			levels = append(levels, []int{})
+		}
+		levels[level] = append(levels[level], index)
+		return levels
+	}
+
+	for index := 0; index < size; index++ {
+		squares, colourToMove := table.decode(index)
+		if !table.placeable(squares) || table.index(squares, colourToMove) != index {
+			unused[index] = true
+			continue
+		}
+		board := table.board(squares, colourToMove)
+		if !board.verifyBoardState() {
+			unused[index] = true
+			continue
+		}
+
+		moves := board.getPossibleMoves()
+		if len(moves) == 0 {
+			if board.isChecked() {
+				lossesAt = addAt(lossesAt, 0, index)
+			} else {
+				resolved[index] = true
+			}
+			continue
+		}
+
+		canLose[index] = true
+		shortestExitWin := -1
+		children := map[int]bool{}
+		for _, child := range moves {
+			if child.endgameMaterial() == material {
+				children[table.index(table.squaresOf(*child), child.colourToMove)] = true
+				continue
+			}
+			wdl, plies, ok := tables.ProbeDTM(*child)
+			if !ok {
+				return nil, fmt.Errorf("%s needs a table for %s", material, child.endgameMaterial())
+			}
+			switch wdl {
+			case WDLLoss:
+				if shortestExitWin < 0 || plies+1 < shortestExitWin {
+					shortestExitWin = plies + 1
+				}
+			case WDLWin:
+				if plies > longestExitLoss[index] {
+					longestExitLoss[index] = plies
+				}
+			default:
+				canLose[index] = false
+			}
+		}
+		remaining[index] = len(children)
+
+		if shortestExitWin > 0 {
+			winsAt = addAt(winsAt, shortestExitWin, index)
+		}
+		if len(children) == 0 && canLose[index] {
+			lossesAt = addAt(lossesAt, longestExitLoss[index]+1, index)
+		}
+	}
+
+	previousPositions := func(index int) []int {
+		squares, colourToMove := table.decode(index)
+		previous := map[int]bool{}
+		for _, unmoved := range table.unmoves(squares, colourToMove) {
+			if previousIndex := table.index(unmoved, colourToMove.opposite()); !unused[previousIndex] && !resolved[previousIndex] {
+				previous[previousIndex] = true
+			}
+		}
+		indexes := []int{}
+		for previousIndex := range previous {
+			indexes = append(indexes, previousIndex)
+		}
+		sort.Ints(indexes)
+		return indexes
+	}
+
+	for level := 0; level < len(winsAt) || level < len(lossesAt); level++ {
+		if level < len(lossesAt) {
+			for _, index := range lossesAt[level] {
+				if resolved[index] {
+					continue
+				}
+				resolved[index] = true
+				table.values[index] = byte(endgameLoss + level)
+				for _, previousIndex := range previousPositions(index) {
+					winsAt = addAt(winsAt, level+1, previousIndex)
+				}
+			}
+		}
+		if level < len(winsAt) {
+			for _, index := range winsAt[level] {
+				if resolved[index] {
+					continue
+				}
+				resolved[index] = true
+				table.values[index] = byte(level)
+				for _, previousIndex := range previousPositions(index) {
+					remaining[previousIndex]--
+					if remaining[previousIndex] == 0 && canLose[previousIndex] {
+						lossLevel := level + 1
+						if longestExitLoss[previousIndex]+1 > lossLevel {
+							lossLevel = longestExitLoss[previousIndex] + 1
+						}
+						lossesAt = addAt(lossesAt, lossLevel, previousIndex)
+					}
+				}
+			}
+		}
+	}
+
+	return table, nil
+}
+
+//longestMate is the most plies to mate of any position in the table
+func (table *EndgameTable) longestMate() int {
+	longest := 0
+	for _, value := range table.values {
+		plies := int(value)
+		if plies >= endgameLoss {
+			plies -= endgameLoss
+		}
+		if plies > longest {
+			longest = plies
+		}
+	}
+	return longest
+}
+
+func writeEndgameTable(table *EndgameTable, dir string) {
+	var out bytes.Buffer
+	writer := gzip.NewWriter(&out)
+	_, _ = writer.Write([]byte(table.material + "\n"))
+	_, _ = writer.Write(table.values)
+	if err := writer.Close(); err != nil {
+		print(err.Error() + "\n")
+		return
+	}
+	_ = ioutil.WriteFile(dir+table.material+".egtb", out.Bytes(), 0644)
+}
+
+func readEndgameTable(fileName, dir string) (*EndgameTable, error) {
+	file, err := ioutil.ReadFile(dir + fileName)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(file))
+	if err != nil {
+		return nil, err
+	}
+	contents, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	split := bytes.IndexByte(contents, '\n')
+	if split < 0 {
+		return nil, fmt.Errorf("%s has no material header", fileName)
+	}
+	table := &EndgameTable{string(contents[:split]), contents[split+1:]}
+	if len(table.values) != endgameTableSize(table.material) {
+		return nil, fmt.Errorf("%s has %d positions but %s needs %d", fileName, len(table.values), table.material, endgameTableSize(table.material))
+	}
+	return table, nil
+}
+
+//loadEndgameTables reads every table in dir
+func loadEndgameTables(dir string) EndgameTables {
+	tables := EndgameTables{}
+	files, _ := ioutil.ReadDir(dir)
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".egtb") {
+			continue
+		}
+		table, err := readEndgameTable(file.Name(), dir)
+		if err != nil {
+			print(err.Error() + "\n")
+			continue
+		}
+		tables[table.material] = table
+	}
+	return tables
+}
+
+//endgameAgreement is the fraction of won positions, out of every step-th position of the table, where evaluate
+//favours the winning side. It scores how well an evaluator understands the endgame
+func endgameAgreement(evaluate func(Board) float64, table *EndgameTable, step int) float64 {
+	agreed := 0
+	total := 0
+	for index := 0; index < len(table.values); index += step {
+		value := table.values[index]
+		if value == 0 {
+			continue
+		}
+		squares, colourToMove := table.decode(index)
+		board := table.board(squares, colourToMove)
+		whiteWins := (value < endgameLoss) == (colourToMove == White)
+		if evaluation := evaluate(board); (evaluation > 0) == whiteWins && evaluation != 0 {
+			agreed++
+		}
+		total++
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(agreed) / float64(total)
+}
+
+//buildEndgameTables builds the given material sets, or all of them, reading the tables they depend on from dir and
+//writing the new ones back to it
+func buildEndgameTables(dir string, materials []string) {
+	if len(materials) == 0 {
+		materials = endgameMaterials
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		print(err.Error() + "\n")
+		return
+	}
+	tables := loadEndgameTables(dir)
+	for _, material := range materials {
+		table, err := generateEndgameTable(material, tables)
+		if err != nil {
+			print(err.Error() + "\n")
+			return
+		}
+		tables[material] = table
+		writeEndgameTable(table, dir)
+		print(fmt.Sprintf("%s: longest mate %d plies\n", material, table.longestMate()))
+	}
+}
+
+//scoreEndgames prints how often a policy's evaluation favours the winning side in each table
+func scoreEndgames(file, dir string, tables EndgameTables) {
+	config := readConfigJson(file, dir).HeauristicConfig.unmarshalJson()
+	evaluate := func(board Board) float64 {
+		return generalHeuristic(&board, &config)
+	}
+	for _, material := range endgameMaterials {
+		if table, ok := tables[material]; ok {
+			print(fmt.Sprintf("%s: %.3f\n", material, endgameAgreement(evaluate, table, 97)))
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+//TestEndgameTableKQK builds the whole KQK table, which takes a long time, so only runs when MLCHESS_ENDGAME_TABLES
+//is set
+func TestEndgameTableKQK(t *testing.T) {
+	if testing.Short() || os.Getenv("MLCHESS_ENDGAME_TABLES") == "" {
+		t.Skip("building KQK takes several seconds, set MLCHESS_ENDGAME_TABLES to run it")
+	}
+	table, err := generateEndgameTable("KQK", EndgameTables{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := EndgameTables{"KQK": table}
+
+	//the longest KQK mate is mate in 10 with white to move
+	if table.longestMate() != 20 {
+		t.Errorf("expected the longest mate to be 20 plies but got %d", table.longestMate())
+	}
+
+	positions := []struct {
+		fen   string
+		wdl   WDL
+		plies int
+	}{
+		{"k7/8/1K6/8/8/8/7Q/8 w - - 0 1", WDLWin, 1},
+		{"k7/2Q5/1K6/8/8/8/8/8 b - - 0 1", WDLDraw, 0},
+		{"K7/8/1k6/8/8/8/7q/8 b - - 0 1", WDLWin, 1},
+		{"K7/8/1k6/8/8/8/8/7q w - - 0 1", WDLLoss, 2},
+		//black takes the queen
+		{"8/8/8/8/8/8/1Qk5/4K3 b - - 0 1", WDLDraw, 0},
+	}
+	for _, position := range positions {
+		state, _ := BoardFromFEN(position.fen)
+		wdl, plies, ok := tables.ProbeDTM(state)
+		if !ok || wdl != position.wdl || plies != position.plies {
+			t.Errorf("%s: expected %d in %d plies but got %d in %d (found %t)", position.fen, position.wdl, position.plies, wdl, plies, ok)
+		}
+	}
+
+	if agreement := endgameAgreement(verySimpleHeuristic, table, 13); agreement != 1 {
+		t.Errorf("material alone should always favour the queen but agreed %f of the time", agreement)
+	}
+
+	dir, err := ioutil.TempDir("", "tablebases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeEndgameTable(table, dir+"/")
+	loaded := loadEndgameTables(dir + "/")
+	if loaded["KQK"] == nil || string(loaded["KQK"].values) != string(table.values) {
+		t.Errorf("KQK did not survive being written and read back")
+	}
+}
+
+func TestEndgameTableKRK(t *testing.T) {
+	table, err := generateEndgameTable("KRK", EndgameTables{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := EndgameTables{"KRK": table}
+
+	//the longest KRK mate is mate in 16 with white to move, so 32 plies with black to move first
+	if table.longestMate() != 32 {
+		t.Errorf("expected the longest mate to be 32 plies but got %d", table.longestMate())
+	}
+
+	positions := []struct {
+		fen   string
+		wdl   WDL
+		plies int
+	}{
+		{"k7/8/1K6/8/8/8/8/7R w - - 0 1", WDLWin, 1},
+		//Kb8 is forced, and then Rh8 is mate
+		{"k7/8/1K6/8/8/8/8/7R b - - 0 1", WDLLoss, 2},
+		{"k1K5/7R/8/8/8/8/8/8 b - - 0 1", WDLDraw, 0},
+		{"K7/8/1k6/8/8/8/8/7r b - - 0 1", WDLWin, 1},
+		//black takes the rook
+		{"8/8/8/8/8/8/1Rk5/4K3 b - - 0 1", WDLDraw, 0},
+	}
+	for _, position := range positions {
+		state, _ := BoardFromFEN(position.fen)
+		wdl, plies, ok := tables.ProbeDTM(state)
+		if !ok || wdl != position.wdl || plies != position.plies {
+			t.Errorf("%s: expected %d in %d plies but got %d in %d (found %t)", position.fen, position.wdl, position.plies, wdl, plies, ok)
+		}
+	}
+}
+
+func TestEndgameTableProbe(t *testing.T) {
+	table := &EndgameTable{"KQK", make([]byte, endgameTableSize("KQK"))}
+	tables := EndgameTables{"KQK": table}
+	positions := []struct {
+		fen   string
+		value byte
+		wdl   WDL
+		plies int
+	}{
+		{"k7/8/1K6/8/8/8/7Q/8 w - - 0 1", 1, WDLWin, 1},
+		{"K7/8/1k6/8/8/8/8/7q w - - 0 1", endgameLoss + 2, WDLLoss, 2},
+		{"k7/2Q5/1K6/8/8/8/8/8 b - - 0 1", 0, WDLDraw, 0},
+	}
+	for _, position := range positions {
+		state, _ := BoardFromFEN(position.fen)
+		if _, ok := tables[state.endgameMaterial()]; !ok {
+			state = state.Mirror()
+		}
+		table.values[table.index(table.squaresOf(state), state.colourToMove)] = position.value
+	}
+	for _, position := range positions {
+		state, _ := BoardFromFEN(position.fen)
+		wdl, plies, ok := tables.ProbeDTM(state)
+		if !ok || wdl != position.wdl || plies != position.plies {
+			t.Errorf("%s: expected %d in %d plies but got %d in %d (found %t)", position.fen, position.wdl, position.plies, wdl, plies, ok)
+		}
+	}
+	if table.longestMate() != 2 {
+		t.Errorf("expected the longest mate to be 2 plies but got %d", table.longestMate())
+	}
+
+	dir, err := ioutil.TempDir("", "tablebases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeEndgameTable(table, dir+"/")
+	loaded := loadEndgameTables(dir + "/")
+	if loaded["KQK"] == nil || string(loaded["KQK"].values) != string(table.values) {
+		t.Errorf("KQK did not survive being written and read back")
+	}
+}
+
+func TestEndgameTableNeedsPromotionTables(t *testing.T) {
+	if _, err := generateEndgameTable("KPK", EndgameTables{}); err == nil {
+		t.Errorf("built KPK without the tables its promotions lead to")
+	}
+	for _, material := range []string{"KXK", "krk", "KQKR"} {
+		if _, err := generateEndgameTable(material, EndgameTables{}); err == nil {
+			t.Errorf("built %s, which the generator does not support", material)
+		}
+	}
+}
+
+func TestEndgameTableIndexIsSymmetric(t *testing.T) {
+	table := &EndgameTable{"KBNK", nil}
+	squares := []Vector{{1, 2}, {6, 5}, {3, 3}, {0, 7}}
+	index := table.index(squares, Black)
+	for _, symmetry := range table.symmetries() {
+		transformed := []Vector{}
+		for _, square := range squares {
+			transformed = append(transformed, symmetry(square))
+		}
+		if table.index(transformed, Black) != index {
+			t.Errorf("%v and %v are the same position but have different indexes", squares, transformed)
+		}
+	}
+	decoded, colour := table.decode(index)
+	if table.index(decoded, colour) != index || colour != Black {
+		t.Errorf("index %d decodes to %v %s", index, decoded, colour)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import "fmt"
+
+//Evaluator scores boards from white's point of view, in pawns
+type Evaluator interface {
+	evaluate(board Board) float64
+}
+
+//MaterialEvaluator counts material, with a little for advanced pawns
+type MaterialEvaluator struct{}
+
+func (MaterialEvaluator) evaluate(board Board) float64 {
+	return verySimpleHeuristic(board)
+}
+
+//HeuristicEvaluator scores boards with a policy's evolved PieceValueConfig. Finished games are left to the searches
+type HeuristicEvaluator struct {
+	config *PieceValueConfig
+}
+
+func (evaluator HeuristicEvaluator) evaluate(board Board) float64 {
+	return generalHeuristic(&board, evaluator.config)
+}
+
+const (
+	//alphaBetaSearch and mctsSearch name the searchers a policy can play with
+	alphaBetaSearch = "alphabeta"
+	mctsSearch      = "mcts"
+	//heuristicEvaluation and materialEvaluation name the evaluators a policy can play with
+	heuristicEvaluation = "heuristic"
+	materialEvaluation  = "material"
+)
+
+//newEvaluator makes the evaluator called name, which defaults to the policy's own heuristic
+func newEvaluator(name string, config *PieceValueConfig) (Evaluator, error) {
+	switch name {
+	case "", heuristicEvaluation:
+		return HeuristicEvaluator{config}, nil
+	case materialEvaluation:
+		return MaterialEvaluator{}, nil
+	default:
+		return nil, fmt.Errorf("unknown evaluator %s", name)
+	}
+}
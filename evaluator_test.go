@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSearchScoresGameEnds(t *testing.T) {
+	config := randomConfig()
+	evaluators := map[string]Evaluator{"material": MaterialEvaluator{}, "heuristic": HeuristicEvaluator{&config}}
+	positions := []struct {
+		fen   string
+		value Score
+	}{
+		{"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", 0},
+		{"R5k1/5ppp/8/8/8/8/8/6K1 b - - 0 1", matedAt(1)},
+		{"6k1/8/8/8/8/8/5PPP/r5K1 w - - 0 1", matedAt(1)},
+	}
+	for name, evaluator := range evaluators {
+		searcher := newAlphaBetaSearcher(evaluator.evaluate, nil, newTranspositionTable(1))
+		for _, position := range positions {
+			board := mustFEN(t, position.fen)
+			if value := searcher.staticValue(&board, 1); value != position.value {
+				t.Errorf("%s scores %s as %s at the horizon but should be %s", name, position.fen, value, position.value)
+			}
+		}
+
+		//the mate is only seen at the horizon, one ply below the root
+		board := mustFEN(t, "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1")
+		if result := searcher.search(context.Background(), &board, 1); result.value != -matedAt(1) || moveName(result.bestMove) != "Ra1a8" {
+			t.Errorf("%s misses the mate in one: %s", name, result)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//BoardFromFEN reads a board from a FEN string. A pocket given in brackets ("...RNBQKBNR[Nn] w") or as a ninth rank makes it a Crazyhouse board
+func BoardFromFEN(fen string) (Board, error) {
+	fields := strings.Fields(fen)
+	if len(fields) == 0 {
+		return Board{}, fmt.Errorf("empty FEN")
+	}
+
+	placement := fields[0]
+	pocket := ""
+	variant := Standard
+	if start := strings.Index(placement, "["); start >= 0 {
+		end := strings.Index(placement, "]")
+		if end < start {
+			return Board{}, fmt.Errorf("unterminated pocket in %q", placement)
+		}
+		pocket = placement[start+1 : end]
+		placement = placement[:start]
+		variant = Crazyhouse
+	}
+
+	ranks := strings.Split(placement, "/")
+	if len(ranks) == 9 {
+		pocket = ranks[8]
+		ranks = ranks[:8]
+		variant = Crazyhouse
+	}
+	if len(ranks) != 8 {
+		return Board{}, fmt.Errorf("expected 8 ranks but got %d", len(ranks))
+	}
+
+	pieces := []*Piece{}
+	promoted := [8][8]bool{}
+	for i, rank := range ranks {
+		y := 7 - i
+		x := 0
+		for _, char := range rank {
+			if char == '~' {
+				if x == 0 {
+					return Board{}, fmt.Errorf("promotion marker without a piece on rank %d", y+1)
+				}
+				promoted[x-1][y] = true
+				continue
+			}
+			if char >= '1' && char <= '8' {
+				x += int(char - '0')
+				continue
+			}
+			pieceType, ok := pieceTypes[strings.ToUpper(string(char))]
+			if !ok {
+				return Board{}, fmt.Errorf("invalid piece %q", char)
+			}
+			if x > 7 {
+				return Board{}, fmt.Errorf("too many squares on rank %d", y+1)
+			}
+			colour := White
+			if strings.ToLower(string(char)) == string(char) {
+				colour = Black
+			}
+			pieces = append(pieces, &Piece{*pieceType, colour, Vector{X: x, Y: y}})
+			x++
+		}
+		if x != 8 {
+			return Board{}, fmt.Errorf("expected 8 squares on rank %d but got %d", y+1, x)
+		}
+	}
+
+	colourToMove := White
+	if len(fields) > 1 {
+		switch fields[1] {
+		case "w":
+		case "b":
+			colourToMove = Black
+		default:
+			return Board{}, fmt.Errorf("invalid colour to move %q", fields[1])
+		}
+	}
+
+	castling := "-"
+	if len(fields) > 2 {
+		castling = fields[2]
+	}
+
+	enPassantRank := -1
+	if len(fields) > 3 && fields[3] != "-" {
+		if len(fields[3]) != 2 || fields[3][0] < 'a' || fields[3][0] > 'h' {
+			return Board{}, fmt.Errorf("invalid en passant square %q", fields[3])
+		}
+		enPassantRank = int(fields[3][0] - 'a')
+	}
+
+	fiftyMoveCounter := 0
+	moveCounter := 1
+	var err error
+	if len(fields) > 4 {
+		if fiftyMoveCounter, err = strconv.Atoi(fields[4]); err != nil {
+			return Board{}, fmt.Errorf("invalid halfmove clock %q", fields[4])
+		}
+	}
+	if len(fields) > 5 {
+		if moveCounter, err = strconv.Atoi(fields[5]); err != nil {
+			return Board{}, fmt.Errorf("invalid move number %q", fields[5])
+		}
+	}
+
+	whitePocket, blackPocket, err := parsePocket(pocket)
+	if err != nil {
+		return Board{}, err
+	}
+
+	return initialiseBoard(variant, pieces, whitePocket, blackPocket, promoted, enPassantRank, colourToMove,
+		strings.Contains(castling, "k"), strings.Contains(castling, "q"), strings.Contains(castling, "K"), strings.Contains(castling, "Q"),
+		"", moveCounter, fiftyMoveCounter), nil
+}
+
+//ToFEN converts board to a FEN string, with the pocket in brackets for Crazyhouse boards
+func (boardState Board) ToFEN() string {
+	out := ""
+	for y := 7; y >= 0; y-- {
+		blankSpaceCounter := 0
+		for x := 0; x < 8; x++ {
+			piece := boardState.squares[x][y]
+			if piece == nil {
+				blankSpaceCounter++
+				continue
+			}
+			if blankSpaceCounter > 0 {
+				out += strconv.Itoa(blankSpaceCounter)
+				blankSpaceCounter = 0
+			}
+			if piece.colour == White {
+				out += strings.ToUpper(piece.pieceType.sign)
+			} else {
+				out += strings.ToLower(piece.pieceType.sign)
+			}
+			if boardState.variant == Crazyhouse && boardState.promoted[x][y] {
+				out += "~"
+			}
+		}
+		if blankSpaceCounter > 0 {
+			out += strconv.Itoa(blankSpaceCounter)
+		}
+		if y > 0 {
+			out += "/"
+		}
+	}
+
+	if boardState.variant == Crazyhouse {
+		out += "[" + boardState.pocketString() + "]"
+	}
+
+	if boardState.colourToMove == White {
+		out += " w "
+	} else {
+		out += " b "
+	}
+
+	castling := ""
+	if boardState.canWhiteKingSideCastle {
+		castling += "K"
+	}
+	if boardState.canWhiteQueenSideCastle {
+		castling += "Q"
+	}
+	if boardState.canBlackKingSideCastle {
+		castling += "k"
+	}
+	if boardState.canBlackQueenSideCastle {
+		castling += "q"
+	}
+	if castling == "" {
+		castling = "-"
+	}
+	out += castling
+
+	if boardState.enPassantRank >= 0 {
+		enPassantSquare := Vector{X: boardState.enPassantRank, Y: 2}
+		if boardState.colourToMove == White {
+			enPassantSquare.Y = 5
+		}
+		out += " " + enPassantSquare.boardPosition()
+	} else {
+		out += " -"
+	}
+
+	out += fmt.Sprintf(" %d %d", boardState.fiftyMoveCounter, boardState.moveCounter)
+	return out
+}
@@ -1,45 +1,139 @@
 package main
 
+//pieceKind is a colour and piece type, which is all a piece's type modifiers depend on
+type pieceKind struct {
+	colour Colour
+	sign   string
+}
+
+//phaseWeights are how much each piece counts towards the game phase, which pawns and kings do not. The pieces of the
+//starting position add up to maxPhase
+var phaseWeights = map[string]int{"N": 1, "B": 1, "R": 2, "Q": 4}
+
+const maxPhase = 24
+
+//gamePhase is 1 in the middlegame, falling to 0 as the non-pawn material on the board and in the pockets comes off
+func gamePhase(boardState *Board) float64 {
+	phase := 0
+	for _, piece := range boardState.pieces {
+		phase += phaseWeights[piece.pieceType.sign]
+	}
+	for _, pocket := range []map[string]int{boardState.whitePocket, boardState.blackPocket} {
+		for sign, count := range pocket {
+			phase += phaseWeights[sign] * count
+		}
+	}
+	if phase > maxPhase {
+		phase = maxPhase
+	}
+	return float64(phase) / maxPhase
+}
+
+//BoardFeatures are the parts of a board's evaluation that do not depend on the config, so are worked out once for
+//both phases: the pieces left on each side, the squares each piece covers, the pawn structure, where the kings are and
+//how safe they are
+type BoardFeatures struct {
+	pieceCounts    map[Colour]int
+	coveredSquares [][]Vector
+	pawns          PawnStructure
+	kings          map[Colour]Vector
+	kingSafety     KingSafety
+}
+
+func boardFeatures(boardState *Board) BoardFeatures {
+	features := BoardFeatures{map[Colour]int{}, make([][]Vector, len(boardState.pieces)), pawnTable.structure(boardState), map[Colour]Vector{}, KingSafety{}}
+	for i, piece := range boardState.pieces {
+		features.pieceCounts[piece.colour]++
+		features.coveredSquares[i] = piece.getCoveredSquares(*boardState)
+		if piece.pieceType.sign == "K" {
+			features.kings[piece.colour] = piece.position
+		}
+	}
+	features.kingSafety = kingSafety(boardState, features.coveredSquares, features.kings)
+	return features
+}
+
+//generalHeuristic values a board with config, from white's point of view. Configs with an endgame variant value the
+//board with both, weighted by the game phase. It takes time in proportion to the number of pieces: the features of
+//the board are worked out once, and the product of a piece's type modifiers over every piece is the same for every
+//piece of its kind, so is multiplied out once per kind in board order
 func generalHeuristic(boardState *Board, config *PieceValueConfig) float64 {
+	features := boardFeatures(boardState)
+	middlegame := config.phaseValue(boardState, features)
+	if config.Endgame == nil {
+		return middlegame
+	}
+	endgame := config.Endgame.phaseValue(boardState, features)
+	phase := gamePhase(boardState)
+	return phase*middlegame + (1-phase)*endgame
+}
+
+//phaseValue values a board with the terms of one phase of a config
+func (config *PieceValueConfig) phaseValue(boardState *Board, features BoardFeatures) float64 {
 	total := 0.0
+	typeModifiers := map[pieceKind][2]float64{}
+	pieceCounts, coveredSquares := features.pieceCounts, features.coveredSquares
 
-	for _, piece := range boardState.pieces {
+	for i, piece := range boardState.pieces {
 		colourMult := 1.0
 		if piece.colour == Black {
 			colourMult = -1
 		}
 
-		noAlliedPieces := 0
-		noOppPieces := 0
-		if piece.colour == White {
-			noAlliedPieces = boardState.noWhitePieces()
-			noOppPieces = boardState.noBlackPieces()
-		} else {
-			noAlliedPieces = boardState.noBlackPieces()
-			noOppPieces = boardState.noWhitePieces()
-		}
-
-		alliedPieceTypeModifiers := []float64{}
-		oppPieceTypeModifiers := []float64{}
+		noAlliedPieces := pieceCounts[piece.colour]
+		noOppPieces := pieceCounts[piece.colour.opposite()]
 
-		for _, otherPiece := range boardState.pieces {
-			if otherPiece.colour == piece.colour {
-				alliedPieceTypeModifiers = append(alliedPieceTypeModifiers, config.RemainingAlliedPiecesTypeMod[piece.pieceType.sign][otherPiece.pieceType.sign])
-			} else {
-				oppPieceTypeModifiers = append(oppPieceTypeModifiers, config.RemainingOpponentPiecesTypeMod[piece.pieceType.sign][otherPiece.pieceType.sign])
-			}
+		kind := pieceKind{piece.colour, piece.pieceType.sign}
+		modifiers, ok := typeModifiers[kind]
+		if !ok {
+			modifiers = config.typeModifiers(boardState, kind)
+			typeModifiers[kind] = modifiers
 		}
 
-		pieceValue := config.BaseValues[piece.pieceType.sign] * config.PositionMod[piece.pieceType.sign][piece.position] *
+		pieceValue := config.BaseValues[piece.pieceType.sign] * config.positionMod(piece) *
 			config.RemainingAlliedPiecesMod[piece.pieceType.sign][noAlliedPieces] * config.RemainingOpponentPiecesMod[piece.pieceType.sign][noOppPieces] *
-			PI(alliedPieceTypeModifiers) * PI(oppPieceTypeModifiers)
+			modifiers[0] * modifiers[1]
 
-		for _, square := range piece.getCoveredSquares(*boardState) {
-			pieceValue += config.SquareBaseValues[Vector{X: square.X, Y: square.Y}] * config.CoveredByMod[piece.pieceType.sign]
+		for _, square := range coveredSquares[i] {
+			pieceValue += config.squareBaseValue(square, piece.colour) * config.CoveredByMod[piece.pieceType.sign]
 		}
 
 		total += pieceValue * colourMult
 	}
 
+	total += pocketHeuristic(boardState.whitePocket, config) - pocketHeuristic(boardState.blackPocket, config)
+	total += config.pawnStructureValue(features.pawns, features.kings)
+	total += config.kingSafetyValue(features.kingSafety)
+
+	return total
+}
+
+//typeModifiers multiplies out a kind of piece's allied and opponent type modifiers over every piece on the board, in
+//board order so the products are exactly what multiplying them piece by piece gives
+func (config *PieceValueConfig) typeModifiers(boardState *Board, kind pieceKind) [2]float64 {
+	allied, opponent := 1.0, 1.0
+	for _, otherPiece := range boardState.pieces {
+		if otherPiece.colour == kind.colour {
+			allied *= config.RemainingAlliedPiecesTypeMod[kind.sign][otherPiece.pieceType.sign]
+		} else {
+			opponent *= config.RemainingOpponentPiecesTypeMod[kind.sign][otherPiece.pieceType.sign]
+		}
+	}
+	return [2]float64{allied, opponent}
+}
+
+//pocketHeuristic values the pieces held in a Crazyhouse pocket. Policies written before pockets existed value them at their base value
+func pocketHeuristic(pocket map[string]int, config *PieceValueConfig) float64 {
+	total := 0.0
+	for sign, count := range pocket {
+		if count == 0 {
+			continue
+		}
+		countMod, ok := config.PocketMod[sign][count]
+		if !ok {
+			countMod = 1
+		}
+		total += config.BaseValues[sign] * countMod * float64(count)
+	}
 	return total
 }
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+var symmetryTestPositions = []string{
+	"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+	"rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR b Kq e3 0 3",
+	"r3k2r/p4pp1/1p5p/1pp5/8/5bP1/PPP4P/R3KBNR b KQkq - 0 1",
+	"3k4/Q7/4K3/p5r1/1p6/1P6/6p1/8 w - - 0 1",
+	"8/ppk5/1pb5/3n4/8/6P1/PP2p3/1KR5 w - - 0 1",
+	"r1bqkb1r/pppp1ppp/2n2n2/4p3/4P3/2N2N2/PPPP1PPP/R1BQKB1R[QPpp] w KQkq - 4 4",
+}
+
+func testEvaluators() map[string]func(Board) float64 {
+	config := randomConfig()
+	return map[string]func(Board) float64{
+		"verySimpleHeuristic": verySimpleHeuristic,
+		"generalHeuristic": func(board Board) float64 {
+			return generalHeuristic(&board, &config)
+		},
+	}
+}
+
+func TestEvaluatorsAreMirrorSymmetric(t *testing.T) {
+	for name, evaluate := range testEvaluators() {
+		for _, fen := range symmetryTestPositions {
+			state, err := BoardFromFEN(fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			value := evaluate(state)
+			mirroredValue := evaluate(state.Mirror())
+			if math.Abs(value+mirroredValue) > 1e-9*math.Max(1, math.Abs(value)) {
+				t.Errorf("%s: %s evaluates to %f but its mirror evaluates to %f", name, fen, value, mirroredValue)
+			}
+		}
+	}
+}
+
+func TestMirrorSwapsSides(t *testing.T) {
+	for _, fen := range symmetryTestPositions {
+		state, err := BoardFromFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mirrored := state.Mirror()
+
+		if mirrored.Mirror().ToFEN() != state.ToFEN() {
+			t.Errorf("mirroring %s twice gives %s", fen, mirrored.Mirror().ToFEN())
+		}
+		if mirrored.colourToMove == state.colourToMove {
+			t.Errorf("mirroring %s did not change the side to move", fen)
+		}
+		if len(mirrored.children) != len(state.children) {
+			t.Errorf("%s has %d moves but its mirror has %d", fen, len(state.children), len(mirrored.children))
+		}
+	}
+
+	state, _ := BoardFromFEN(symmetryTestPositions[1])
+	if state.Mirror().ToFEN() != "rnbqkbnr/pppp1ppp/8/3Pp3/8/8/PPP1PPPP/RNBQKBNR w Qk e6 0 3" {
+		t.Errorf("unexpected mirrored position %s", state.Mirror().ToFEN())
+	}
+}
+
+func TestMirrorAugment(t *testing.T) {
+	positions := mirrorAugment([]LabelledPosition{{FEN: symmetryTestPositions[1], Value: 1.5}})
+	if len(positions) != 2 {
+		t.Fatalf("expected original and mirrored position but got %d positions", len(positions))
+	}
+	if positions[1].Value != -1.5 || positions[1].FEN != "rnbqkbnr/pppp1ppp/8/3Pp3/8/8/PPP1PPPP/RNBQKBNR w Qk e6 0 3" {
+		t.Errorf("unexpected mirrored position %v", positions[1])
+	}
+}
+
+func TestSideRelativeMigrationKeepsEvaluations(t *testing.T) {
+	legacyConfig := randomConfig()
+	legacyConfig.SideRelative = false
+	migratedConfig := legacyConfig.marshalJson().unmarshalJson().toSideRelative().marshalJson().unmarshalJson()
+
+	if !migratedConfig.SideRelative {
+		t.Errorf("migrated config is not side relative")
+	}
+	for _, fen := range symmetryTestPositions {
+		state, err := BoardFromFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		legacyValue := generalHeuristic(&state, &legacyConfig)
+		migratedValue := generalHeuristic(&state, &migratedConfig)
+		if math.Abs(legacyValue-migratedValue) > 1e-9*math.Max(1, math.Abs(legacyValue)) {
+			t.Errorf("%s evaluates to %f before migration but %f after", fen, legacyValue, migratedValue)
+		}
+	}
+}
+
+//quadraticHeuristic is generalHeuristic as it was first written, working out every piece's modifiers from scratch, with
+//the pawn structure worked out without the pawn table and the safety of the kings
+func quadraticHeuristic(boardState *Board, config *PieceValueConfig) float64 {
+	total := 0.0
+	kings := map[Colour]Vector{}
+	coveredSquares := [][]Vector{}
+
+	for _, piece := range boardState.pieces {
+		colourMult := 1.0
+		if piece.colour == Black {
+			colourMult = -1
+		}
+
+		if piece.pieceType.sign == "K" {
+			kings[piece.colour] = piece.position
+		}
+
+		noAlliedPieces := 0
+		noOppPieces := 0
+		if piece.colour == White {
+			noAlliedPieces = boardState.noWhitePieces()
+			noOppPieces = boardState.noBlackPieces()
+		} else {
+			noAlliedPieces = boardState.noBlackPieces()
+			noOppPieces = boardState.noWhitePieces()
+		}
+
+		alliedPieceTypeModifiers := []float64{}
+		oppPieceTypeModifiers := []float64{}
+
+		for _, otherPiece := range boardState.pieces {
+			if otherPiece.colour == piece.colour {
+				alliedPieceTypeModifiers = append(alliedPieceTypeModifiers, config.RemainingAlliedPiecesTypeMod[piece.pieceType.sign][otherPiece.pieceType.sign])
+			} else {
+				oppPieceTypeModifiers = append(oppPieceTypeModifiers, config.RemainingOpponentPiecesTypeMod[piece.pieceType.sign][otherPiece.pieceType.sign])
+			}
+		}
+
+		pieceValue := config.BaseValues[piece.pieceType.sign] * config.positionMod(piece) *
+			config.RemainingAlliedPiecesMod[piece.pieceType.sign][noAlliedPieces] * config.RemainingOpponentPiecesMod[piece.pieceType.sign][noOppPieces] *
+			PI(alliedPieceTypeModifiers) * PI(oppPieceTypeModifiers)
+
+		coveredSquares = append(coveredSquares, piece.getCoveredSquares(*boardState))
+		for _, square := range coveredSquares[len(coveredSquares)-1] {
+			pieceValue += config.squareBaseValue(square, piece.colour) * config.CoveredByMod[piece.pieceType.sign]
+		}
+
+		total += pieceValue * colourMult
+	}
+
+	total += pocketHeuristic(boardState.whitePocket, config) - pocketHeuristic(boardState.blackPocket, config)
+	total += config.pawnStructureValue(pawnStructure(boardState), kings)
+	total += referenceKingSafetyValue(boardState, config, coveredSquares, kings)
+
+	return total
+}
+
+//referenceKingSafetyValue scores king safety without kingSafety or kingSafetyValue, walking up the files from each
+//king and taking the king zone from the squares each piece covers. It adds the terms in the same order
+//kingSafetyValue does, so the two agree exactly
+func referenceKingSafetyValue(boardState *Board, config *PieceValueConfig, coveredSquares [][]Vector, kings map[Colour]Vector) float64 {
+	total := 0.0
+	for _, colour := range []Colour{White, Black} {
+		king, ok := kings[colour]
+		if !ok {
+			continue
+		}
+		forward := 1
+		if colour == Black {
+			forward = -1
+		}
+
+		shield, storm := [8]int{}, [8]int{}
+		openFiles, semiOpenFiles := 0, 0
+		for file := king.X - 1; file <= king.X+1; file++ {
+			if file < 0 || file > 7 {
+				continue
+			}
+			ownPawns, enemyPawns := false, false
+			for rank := 0; rank < 8; rank++ {
+				if piece := boardState.squares[file][rank]; piece != nil && piece.pieceType.sign == "P" {
+					ownPawns = ownPawns || piece.colour == colour
+					enemyPawns = enemyPawns || piece.colour != colour
+				}
+			}
+			if !ownPawns && !enemyPawns {
+				openFiles++
+			} else if !ownPawns {
+				semiOpenFiles++
+			}
+
+			ownAhead, enemyAhead := 0, 0
+			for ahead := 1; king.Y+ahead*forward >= 0 && king.Y+ahead*forward < 8; ahead++ {
+				piece := boardState.squares[file][king.Y+ahead*forward]
+				if piece == nil || piece.pieceType.sign != "P" {
+					continue
+				}
+				if piece.colour == colour && ownAhead == 0 {
+					ownAhead = ahead
+				} else if piece.colour != colour && enemyAhead == 0 {
+					enemyAhead = ahead
+				}
+			}
+			shield[ownAhead]++
+			storm[enemyAhead]++
+		}
+
+		zone := map[Vector]bool{}
+		attacks := map[string]int{}
+		attackers := 0
+		for i, piece := range boardState.pieces {
+			if piece.colour == colour {
+				continue
+			}
+			attacking := 0
+			for _, square := range coveredSquares[i] {
+				if math.Abs(float64(square.X-king.X)) <= 1 && math.Abs(float64(square.Y-king.Y)) <= 1 {
+					zone[square] = true
+					attacking++
+				}
+			}
+			if attacking > 0 {
+				attacks[piece.pieceType.sign] += attacking
+				attackers++
+			}
+		}
+
+		value := config.OpenFileNearKing*float64(openFiles) + config.SemiOpenFileNearKing*float64(semiOpenFiles) +
+			config.KingZoneCovered*float64(len(zone))
+		for ahead := 1; ahead < 8; ahead++ {
+			value += config.PawnShield[ahead]*float64(shield[ahead]) + config.PawnStorm[ahead]*float64(storm[ahead])
+		}
+		attack := 0.0
+		for _, sign := range []string{"P", "N", "B", "R", "Q", "K"} {
+			attack += config.KingZoneAttackMod[sign] * float64(attacks[sign])
+		}
+		value += attack * config.KingAttackerCountMod[attackers]
+
+		if colour == Black {
+			value = -value
+		}
+		total += value
+	}
+	return total
+}
+
+//taperedQuadraticHeuristic blends quadraticHeuristic's values for the middlegame and endgame terms of config by phase
+func taperedQuadraticHeuristic(boardState *Board, config *PieceValueConfig) float64 {
+	if config.Endgame == nil {
+		return quadraticHeuristic(boardState, config)
+	}
+	middlegame := *config
+	middlegame.Endgame = nil
+	phase := gamePhase(boardState)
+	return phase*quadraticHeuristic(boardState, &middlegame) + (1-phase)*quadraticHeuristic(boardState, config.Endgame)
+}
+
+func TestGeneralHeuristicMatchesQuadratic(t *testing.T) {
+	random := rand.New(rand.NewSource(7))
+	for i := 0; i < 4; i++ {
+		config := randomConfig()
+		//configs from before tapering have no endgame terms
+		if i == 0 {
+			config.Endgame = nil
+		}
+		for _, fen := range symmetryTestPositions {
+			state, err := BoardFromFEN(fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			//random games reach positions with captures and promotions
+			for ply := 0; ply < 40; ply++ {
+				if value, expected := generalHeuristic(&state, &config), taperedQuadraticHeuristic(&state, &config); value != expected {
+					t.Fatalf("%s evaluates to %v but should be %v", state.ToFEN(), value, expected)
+				}
+				moves := state.getPossibleMoves()
+				if len(moves) == 0 {
+					break
+				}
+				state = *moves[random.Intn(len(moves))]
+			}
+		}
+	}
+}
+
+func TestTaperedEvaluation(t *testing.T) {
+	opening := NewBoard()
+	ending := mustFEN(t, "4k3/4p3/8/8/8/8/4P3/4K3 w - - 0 1")
+	rookEnding := mustFEN(t, "4k3/4p3/8/8/8/8/4P3/R3K3 w - - 0 1")
+	if gamePhase(&opening) != 1 || gamePhase(&ending) != 0 || gamePhase(&rookEnding) != 2.0/maxPhase {
+		t.Errorf("expected phases 1, 0 and 1/12 but got %f, %f and %f", gamePhase(&opening), gamePhase(&ending), gamePhase(&rookEnding))
+	}
+
+	config := randomConfig()
+	middlegame, endgame := config, *config.Endgame
+	middlegame.Endgame = nil
+	if generalHeuristic(&opening, &config) != generalHeuristic(&opening, &middlegame) {
+		t.Errorf("the opening should be valued with the middlegame terms alone")
+	}
+	if generalHeuristic(&ending, &config) != generalHeuristic(&ending, &endgame) {
+		t.Errorf("a king and pawn ending should be valued with the endgame terms alone")
+	}
+
+	//kings go home in the middlegame and to the centre in the endgame
+	home, centre := Vector{X: 6, Y: 0}, Vector{X: 3, Y: 3}
+	if middlegame.PositionMod["K"][home] < middlegame.PositionMod["K"][centre]-0.2 || endgame.PositionMod["K"][centre] < endgame.PositionMod["K"][home]-0.2 {
+		t.Errorf("unexpected king tables")
+	}
+
+	roundTrip := config.marshalJson().unmarshalJson()
+	if roundTrip.Endgame == nil || generalHeuristic(&rookEnding, &roundTrip) != generalHeuristic(&rookEnding, &config) {
+		t.Errorf("the endgame terms did not survive JSON")
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+//KingSafety is what surrounds each side's king, by colour index, which does not depend on the policy scoring it.
+//shield and storm count the nearest own and enemy pawn in front of the king on its file and those beside it, by how
+//many ranks in front they are. The king zone is the king's square and the squares next to it
+type KingSafety struct {
+	shield        [2][8]int
+	storm         [2][8]int
+	openFiles     [2]int
+	semiOpenFiles [2]int
+	//zoneCovered counts the king zone squares the enemy covers, zoneAttacks the king zone squares each enemy piece
+	//type covers, counted once per piece, and attackers how many enemy pieces cover any of them
+	zoneCovered [2]int
+	zoneAttacks [2]map[string]int
+	attackers   [2]int
+}
+
+//kingSafety finds the pawns around each king and the enemy pieces bearing down on it, given the squares each piece
+//of the board covers
+func kingSafety(board *Board, coveredSquares [][]Vector, kings map[Colour]Vector) KingSafety {
+	safety := KingSafety{zoneAttacks: [2]map[string]int{{}, {}}}
+	for _, colour := range []Colour{White, Black} {
+		king, ok := kings[colour]
+		if !ok {
+			continue
+		}
+		side := colourIndex(colour)
+		kingRank := relativeRank(king.Y, colour)
+
+		for file := king.X - 1; file <= king.X+1; file++ {
+			if file < 0 || file > 7 {
+				continue
+			}
+			shield, storm := 0, 0
+			ownPawns, enemyPawns := false, false
+			for _, piece := range board.pieces {
+				if piece.pieceType.sign != "P" || piece.position.X != file {
+					continue
+				}
+				ahead := relativeRank(piece.position.Y, colour) - kingRank
+				if piece.colour == colour {
+					ownPawns = true
+					if ahead > 0 && (shield == 0 || ahead < shield) {
+						shield = ahead
+					}
+				} else {
+					enemyPawns = true
+					if ahead > 0 && (storm == 0 || ahead < storm) {
+						storm = ahead
+					}
+				}
+			}
+			safety.shield[side][shield]++
+			safety.storm[side][storm]++
+			if !ownPawns && !enemyPawns {
+				safety.openFiles[side]++
+			} else if !ownPawns {
+				safety.semiOpenFiles[side]++
+			}
+		}
+
+		enemyCovered := board.coveredSquaresBlack
+		if colour == Black {
+			enemyCovered = board.coveredSquaresWhite
+		}
+		if enemyCovered != nil {
+			for x := king.X - 1; x <= king.X+1; x++ {
+				for y := king.Y - 1; y <= king.Y+1; y++ {
+					if x >= 0 && x < 8 && y >= 0 && y < 8 && enemyCovered[x][y] {
+						safety.zoneCovered[side]++
+					}
+				}
+			}
+		}
+
+		for i, piece := range board.pieces {
+			if piece.colour == colour {
+				continue
+			}
+			attacks := 0
+			for _, square := range coveredSquares[i] {
+				if kingDistance(square, king) <= 1 {
+					attacks++
+				}
+			}
+			if attacks > 0 {
+				safety.zoneAttacks[side][piece.pieceType.sign] += attacks
+				safety.attackers[side]++
+			}
+		}
+	}
+	return safety
+}
+
+//kingSafetyValue scores the safety of both kings with the config's terms, from white's point of view. The shield and
+//storm terms have no entry for files without a pawn in front of the king, which are scored as open or semi-open
+//files instead. The squares enemy pieces cover in the king zone are weighted by KingZoneAttackMod for the type of
+//piece, and their total by KingAttackerCountMod for the number of pieces attacking, since lone attackers rarely
+//get anywhere
+func (config *PieceValueConfig) kingSafetyValue(safety KingSafety) float64 {
+	total := 0.0
+	for side, colour := range []Colour{White, Black} {
+		value := config.OpenFileNearKing*float64(safety.openFiles[side]) + config.SemiOpenFileNearKing*float64(safety.semiOpenFiles[side]) +
+			config.KingZoneCovered*float64(safety.zoneCovered[side])
+		for ahead := 1; ahead < 8; ahead++ {
+			value += config.PawnShield[ahead]*float64(safety.shield[side][ahead]) + config.PawnStorm[ahead]*float64(safety.storm[side][ahead])
+		}
+
+		//in a fixed order, so the same board always sums to exactly the same value
+		attack := 0.0
+		for _, sign := range []string{"P", "N", "B", "R", "Q", "K"} {
+			attack += config.KingZoneAttackMod[sign] * float64(safety.zoneAttacks[side][sign])
+		}
+		value += attack * config.KingAttackerCountMod[safety.attackers[side]]
+
+		if colour == Black {
+			value = -value
+		}
+		total += value
+	}
+	return total
+}
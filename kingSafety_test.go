@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKingSafety(t *testing.T) {
+	tests := []struct {
+		fen                 string
+		shield, storm       [2][8]int
+		openFiles, semiOpen [2]int
+		zoneCovered         [2]int
+		zoneAttacks         [2]map[string]int
+		attackers           [2]int
+	}{
+		//both kings castled behind unmoved pawns, which the other side's pawns are six ranks from
+		{"6k1/5ppp/8/8/8/8/5PPP/6K1 w - - 0 1", [2][8]int{{1: 3}, {1: 3}}, [2][8]int{{6: 3}, {6: 3}}, [2]int{}, [2]int{},
+			[2]int{}, [2]map[string]int{{}, {}}, [2]int{}},
+		//the g pawns have gone, opening the g file to a rook on g8 that covers g2 and g1, while the queen covers f2, g2
+		//and h1. The black king in the corner has only the g and h files beside it
+		{"6rk/7p/8/8/8/5q2/5P1P/6K1 w - - 0 1", [2][8]int{{0: 1, 1: 2}, {0: 1, 1: 1}}, [2][8]int{{0: 2, 6: 1}, {0: 1, 6: 1}}, [2]int{1, 1}, [2]int{},
+			[2]int{4, 0}, [2]map[string]int{{"Q": 3, "R": 2}, {}}, [2]int{2, 0}},
+	}
+	for _, test := range tests {
+		board := mustFEN(t, test.fen)
+		features := boardFeatures(&board)
+		safety := features.kingSafety
+		if safety.shield != test.shield || safety.storm != test.storm || safety.openFiles != test.openFiles || safety.semiOpenFiles != test.semiOpen {
+			t.Errorf("%s has shield %v, storm %v, open files %v and semi-open files %v", test.fen, safety.shield, safety.storm, safety.openFiles, safety.semiOpenFiles)
+		}
+		if safety.zoneCovered != test.zoneCovered || !reflect.DeepEqual(safety.zoneAttacks, test.zoneAttacks) || safety.attackers != test.attackers {
+			t.Errorf("%s has %v king zone squares covered by %v from %v attackers", test.fen, safety.zoneCovered, safety.zoneAttacks, safety.attackers)
+		}
+	}
+}
+
+func TestPawnShieldPenalisesPushes(t *testing.T) {
+	config := PieceValueConfig{PawnShield: map[int]float64{1: 0.5, 2: 0.25}}
+	castled := mustFEN(t, "r4rk1/ppp2ppp/2n5/8/8/2N5/PPP2PPP/R4RK1 w - - 0 1")
+	pushed := mustFEN(t, "r4rk1/ppp2ppp/2n5/8/6P1/2N5/PPP2P1P/R4RK1 b - - 0 1")
+	castledSafety, pushedSafety := boardFeatures(&castled).kingSafety, boardFeatures(&pushed).kingSafety
+	if value := config.kingSafetyValue(pushedSafety) - config.kingSafetyValue(castledSafety); value != -0.5 {
+		t.Errorf("pushing the g pawn two squares should lose the pawn shield's 0.5 but changed the value by %f", value)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//startHelpers starts the searcher's extra threads, Lazy SMP style: each helper searches the same position by
+//iterative deepening with its own move ordering, and they help each other only through the transposition table they
+//share. Half of the helpers start a ply deeper, so the threads spread over different depths. The returned function
+//stops the helpers and gives the nodes they searched
+func (searcher *AlphaBetaSearcher) startHelpers(board *Board) func() int {
+	if searcher.options.Threads <= 1 || searcher.table == nil {
+		return func() int { return 0 }
+	}
+
+	abort := int32(0)
+	helpers := []*AlphaBetaSearcher{}
+	var running sync.WaitGroup
+	for i := 1; i < searcher.options.Threads; i++ {
+		helper := &AlphaBetaSearcher{searcher.evaluate, searcher.tablebase, searcher.table, newMoveOrderer(), searcher.options, 0, 0, SearchLimits{}, time.Time{}, true, false, &abort, 0, nil, nil, nil, SearchResult{}}
+		helpers = append(helpers, helper)
+		running.Add(1)
+		go func(helper *AlphaBetaSearcher, firstDepth int) {
+			defer running.Done()
+			for depth := firstDepth; depth <= maxSearchDepth && !helper.stopped; depth++ {
+				helper.rootDepth = depth
+				helper.negamax(board, depth, 0, -infiniteScore, infiniteScore)
+			}
+		}(helper, 1+i%2)
+	}
+
+	return func() int {
+		atomic.StoreInt32(&abort, 1)
+		running.Wait()
+		nodes := 0
+		for _, helper := range helpers {
+			nodes += helper.nodes
+		}
+		return nodes
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLazySMP(t *testing.T) {
+	board, _ := BoardFromFEN("r1bqkbnr/pppp1ppp/2n5/4p3/3PP3/8/PPP2PPP/RNBQKBNR w KQkq - 0 3")
+	search := func(threads int, limits SearchLimits) SearchResult {
+		searcher := newAlphaBetaSearcher(verySimpleHeuristic, nil, newTranspositionTable(1))
+		searcher.options.Threads = threads
+		return searcher.think(context.Background(), &board, limits)
+	}
+
+	first, second := search(1, SearchLimits{Depth: 3}), search(1, SearchLimits{Depth: 3})
+	if first.value != second.value || first.nodes != second.nodes || first.bestMove.lastMoveString != second.bestMove.lastMoveString {
+		t.Errorf("one thread gave %s %f in %d nodes and then %s %f in %d", first.bestMove.lastMoveString, first.value, first.nodes,
+			second.bestMove.lastMoveString, second.value, second.nodes)
+	}
+
+	legal := map[string]bool{}
+	for _, child := range board.getPossibleMoves() {
+		legal[child.lastMoveString] = true
+	}
+	single := search(1, SearchLimits{Depth: 2})
+	for _, limits := range []SearchLimits{{Depth: 2}, {Time: 100 * time.Millisecond}} {
+		result := search(4, limits)
+		if result.bestMove == nil || !legal[result.bestMove.lastMoveString] {
+			t.Errorf("%+v: four threads did not give a legal move", limits)
+		}
+		if result.nodes <= single.nodes && limits.Depth == 2 {
+			t.Errorf("expected the helpers' nodes to be counted")
+		}
+	}
+
+	mate, _ := BoardFromFEN("r5k1/5ppp/8/8/8/8/1Q6/1R4K1 w - - 0 1")
+	searcher := newAlphaBetaSearcher(verySimpleHeuristic, nil, newTranspositionTable(1))
+	searcher.options.Threads = 3
+	if result := searcher.search(context.Background(), &mate, 4); result.value.mateIn() != 2 || result.bestMove.lastMoveString != "Qb2b8 " {
+		t.Errorf("three threads did not find the mate")
+	}
+}
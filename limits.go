@@ -0,0 +1,69 @@
+package main
+
+import "time"
+
+//maxSearchDepth bounds iterative deepening when nothing else does
+const maxSearchDepth = 64
+
+//defaultMovesToGo is how many more moves a clock is assumed to need when the time control does not say
+const defaultMovesToGo = 30
+
+//SearchLimits bound a search. Zero values are no limit. Remaining, Increment and MovesToGo describe the player's
+//clock, which the time for the move is taken from when Time is not set. Durations are written to JSON in nanoseconds
+type SearchLimits struct {
+	Depth     int           `json:"depth,omitempty"`
+	Nodes     int           `json:"nodes,omitempty"`
+	Time      time.Duration `json:"time,omitempty"`
+	Remaining time.Duration `json:"remaining,omitempty"`
+	Increment time.Duration `json:"increment,omitempty"`
+	MovesToGo int           `json:"movesToGo,omitempty"`
+}
+
+//moveTime is how long to think about one move: the fixed time when there is one, and otherwise an even share of
+//the clock plus most of the increment, never more than half of what is left
+func (limits SearchLimits) moveTime() time.Duration {
+	if limits.Time > 0 {
+		return limits.Time
+	}
+	if limits.Remaining <= 0 {
+		return 0
+	}
+	movesToGo := limits.MovesToGo
+	if movesToGo <= 0 {
+		movesToGo = defaultMovesToGo
+	}
+	budget := limits.Remaining/time.Duration(movesToGo) + limits.Increment*3/4
+	if budget > limits.Remaining/2 {
+		budget = limits.Remaining / 2
+	}
+	return budget
+}
+
+//maxDepth is the deepest iteration to search
+func (limits SearchLimits) maxDepth() int {
+	if limits.Depth > 0 {
+		return limits.Depth
+	}
+	return maxSearchDepth
+}
+
+//spend takes the time a move used off a clock playing under control, returning false when the clock ran out. Games
+//without a clock never run out
+func (clock *SearchLimits) spend(elapsed time.Duration, control SearchLimits) bool {
+	if control.Remaining <= 0 {
+		return true
+	}
+	clock.Remaining -= elapsed
+	if clock.Remaining <= 0 {
+		return false
+	}
+	clock.Remaining += clock.Increment
+	if control.MovesToGo > 0 {
+		clock.MovesToGo--
+		if clock.MovesToGo == 0 {
+			clock.MovesToGo = control.MovesToGo
+			clock.Remaining += control.Remaining
+		}
+	}
+	return true
+}
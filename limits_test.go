@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMoveTime(t *testing.T) {
+	limits := []struct {
+		limits   SearchLimits
+		expected time.Duration
+	}{
+		{SearchLimits{Depth: 4}, 0},
+		{SearchLimits{Time: time.Second, Remaining: time.Minute}, time.Second},
+		{SearchLimits{Remaining: time.Minute}, 2 * time.Second},
+		{SearchLimits{Remaining: time.Minute, Increment: 4 * time.Second}, 5 * time.Second},
+		{SearchLimits{Remaining: time.Minute, MovesToGo: 10}, 6 * time.Second},
+		//never more than half the clock
+		{SearchLimits{Remaining: 4 * time.Second, Increment: 8 * time.Second}, 2 * time.Second},
+	}
+	for _, limit := range limits {
+		if moveTime := limit.limits.moveTime(); moveTime != limit.expected {
+			t.Errorf("%+v: expected %s but got %s", limit.limits, limit.expected, moveTime)
+		}
+	}
+}
+
+func TestClockSpend(t *testing.T) {
+	control := SearchLimits{Remaining: time.Minute, Increment: time.Second, MovesToGo: 2}
+	clock := control
+	if !clock.spend(10*time.Second, control) || clock.Remaining != 51*time.Second || clock.MovesToGo != 1 {
+		t.Errorf("expected 51s for 1 move but got %s for %d", clock.Remaining, clock.MovesToGo)
+	}
+	if !clock.spend(time.Second, control) || clock.Remaining != 111*time.Second || clock.MovesToGo != 2 {
+		t.Errorf("expected the next time control to add a minute but got %s for %d", clock.Remaining, clock.MovesToGo)
+	}
+	if clock.spend(2*time.Minute, control) {
+		t.Errorf("the clock should have run out")
+	}
+
+	untimed := SearchLimits{Depth: 4}
+	if !untimed.spend(time.Hour, untimed) {
+		t.Errorf("a game without a clock ran out of time")
+	}
+}
@@ -1,11 +1,187 @@
 package main
 
-type player struct {
-	colour   string
-	strategy string
-}
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+)
 
 func main() {
+	//interrupting stops a search with the best move found so far, and ends a tournament after saving its finished games
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			migratePolicies("./policies/")
+		case "mate":
+			if len(os.Args) != 4 {
+				print("usage: mate <fen> <n>\n")
+				os.Exit(2)
+			}
+			n, err := strconv.Atoi(os.Args[3])
+			if err != nil {
+				print("n must be a number of moves\n")
+				os.Exit(2)
+			}
+			solveMateCommand(ctx, os.Args[2], n)
+		case "tablebase":
+			for _, material := range os.Args[2:] {
+				if !isEndgameMaterial(material) {
+					print("unknown material " + material + ", expected one of " + strings.Join(endgameMaterials, " ") + "\n")
+					os.Exit(2)
+				}
+			}
+			buildEndgameTables("./tablebases/", os.Args[2:])
+		case "endgame":
+			if len(os.Args) != 3 {
+				print("usage: endgame <policy file>\n")
+				os.Exit(2)
+			}
+			scoreEndgames(os.Args[2], "./policies/", loadEndgameTables("./tablebases/"))
+		case "book":
+			bookCommand(os.Args[2:])
+		case "analyse":
+			analyseCommand(ctx, os.Args[2:])
+		default:
+			print("unknown command " + os.Args[1] + "\n")
+			os.Exit(2)
+		}
+		return
+	}
+
 	writeRandomConfigs("./policies/", 2)
-	tournament("./policies/")
+	tournament(ctx, "./policies/", ArenaConfig{arenaLimits, arenaHashMB, arenaLogSearch, loadTablebase(), loadOpeningBook(), "./games/", arenaMultiPV, arenaTemperature})
+}
+
+//arenaLimits give each player in the arena the same clock, so policies are compared at equal thinking time
+var arenaLimits = SearchLimits{Remaining: 5 * time.Minute, Increment: 3 * time.Second}
+
+//arenaHashMB is the size of each player's transposition table in megabytes
+const arenaHashMB = 16
+
+//arenaLogSearch prints every iteration of every search in arena games
+const arenaLogSearch = false
+
+//arenaMultiPV and arenaTemperature let players choose among their best few moves, so the same two policies do not
+//play the same game every time
+const arenaMultiPV = 3
+const arenaTemperature = 0.1
+
+//loadTablebase uses the Syzygy tables when there are any, and otherwise the tables built by the tablebase command
+func loadTablebase() Tablebase {
+	if syzygy := loadSyzygy("./syzygy/"); syzygy.maxPieces > 0 {
+		return syzygy
+	}
+	return loadEndgameTables("./tablebases/")
+}
+
+//loadOpeningBook reads the Polyglot book ./books/book.bin. Games start without a book when it is missing
+func loadOpeningBook() *PolyglotBook {
+	if _, err := os.Stat("./books/book.bin"); err != nil {
+		return nil
+	}
+	return loadPolyglotBook("./books/book.bin")
+}
+
+//bookCommand builds an opening book from PGN files and arena game records. Books ending in .bin are written in Polyglot
+//format and anything else as a JSON opening tree
+func bookCommand(args []string) {
+	flags := flag.NewFlagSet("book", flag.ExitOnError)
+	plies := flags.Int("plies", 16, "how many plies of each game go into the book")
+	minGames := flags.Int("min", 1, "leave out moves played in fewer games than this")
+	results := flags.String("results", "", "comma separated results to keep, such as 1-0,1/2-1/2")
+	players := flags.String("players", "", "comma separated policies whose games to keep")
+	_ = flags.Parse(args)
+	if flags.NArg() < 2 {
+		print("usage: book [flags] <book.bin|book.json> <games.pgn|games.json>...\n")
+		os.Exit(2)
+	}
+
+	options := OpeningBookOptions{*plies, *minGames, []WinState{}, []string{}}
+	if *results != "" {
+		for _, token := range strings.Split(*results, ",") {
+			result, ok := pgnResults[token]
+			if !ok {
+				print("unknown result " + token + "\n")
+				os.Exit(2)
+			}
+			options.Results = append(options.Results, result)
+		}
+	}
+	if *players != "" {
+		options.Players = strings.Split(*players, ",")
+	}
+
+	if err := buildOpeningBook(flags.Args()[1:], flags.Arg(0), options); err != nil {
+		print(err.Error() + "\n")
+		os.Exit(1)
+	}
+}
+
+//analyseCommand searches a FEN position, printing the principal variation and statistics of every iteration. An
+//interrupted analysis still prints its best move
+func analyseCommand(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("analyse", flag.ExitOnError)
+	depth := flags.Int("depth", 0, "deepest iteration to search, 0 for no limit")
+	moveTime := flags.Duration("time", 10*time.Second, "how long to search, 0 for no limit")
+	threads := flags.Int("threads", 1, "search threads")
+	hashMB := flags.Int("hash", arenaHashMB, "transposition table size in megabytes")
+	multiPV := flags.Int("multipv", 1, "how many of the best moves to show")
+	mcts := flags.Bool("mcts", false, "search with Monte Carlo tree search instead of alpha-beta")
+	playouts := flags.Int("playouts", 0, "how many playouts to run with -mcts, 0 for no limit")
+	policy := flags.String("policy", "", "evaluate with the heuristic of this policy file in ./policies/ instead of material")
+	_ = flags.Parse(args)
+	if flags.NArg() == 0 {
+		print("usage: analyse [flags] <fen>\n")
+		os.Exit(2)
+	}
+	board, err := BoardFromFEN(strings.Join(flags.Args(), " "))
+	if err != nil {
+		print(err.Error() + "\n")
+		os.Exit(2)
+	}
+
+	printInfo := func(result SearchResult) {
+		if len(result.lines) <= 1 {
+			print(result.String() + "\n")
+			return
+		}
+		for i, line := range result.lines {
+			print(fmt.Sprintf("multipv %d %s\n", i+1, line))
+		}
+	}
+	var evaluator Evaluator = MaterialEvaluator{}
+	if *policy != "" {
+		config := readConfigJson(*policy, "./policies/").HeauristicConfig.unmarshalJson()
+		evaluator = HeuristicEvaluator{&config}
+	}
+	var searcher Searcher
+	if *mcts {
+		treeSearcher := newMCTSSearcher(evaluator.evaluate, nil, loadTablebase())
+		treeSearcher.options.MultiPV = *multiPV
+		treeSearcher.onInfo = printInfo
+		searcher = treeSearcher
+	} else {
+		alphaBeta := newAlphaBetaSearcher(evaluator.evaluate, loadTablebase(), newTranspositionTable(*hashMB))
+		alphaBeta.options.Threads = *threads
+		alphaBeta.options.MultiPV = *multiPV
+		alphaBeta.onInfo = printInfo
+		searcher = alphaBeta
+	}
+	result := searcher.think(ctx, &board, SearchLimits{Depth: *depth, Nodes: *playouts, Time: *moveTime})
+	if *mcts {
+		printInfo(result)
+	}
+	if result.bestMove == nil {
+		print("no moves\n")
+		return
+	}
+	print("bestmove " + moveName(result.bestMove) + "\n")
 }
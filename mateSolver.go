@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//MateNode is a node of a proven forced mate. An attacking node holds the board after the attacking move and one
+//child for every defence, while a defending node holds the board after the defence and the single attacking answer
+type MateNode struct {
+	state    *Board
+	children []*MateNode
+}
+
+//solveMate proves a forced mate in at most n moves for the side to move by exhaustive search. It returns the
+//attacking move of the shortest mate with every defence answered, or nil when there is no forced mate in n or ctx was
+//cancelled before one was proven
+func solveMate(ctx context.Context, state Board, n int) *MateNode {
+	state.children = state.getPossibleMoves()
+	for depth := 1; depth <= n; depth++ {
+		if solution := searchMate(ctx, state, depth); solution != nil {
+			return solution
+		}
+	}
+	return nil
+}
+
+//searchMate looks for a forced mate in at most n moves from a board with its children already generated
+func searchMate(ctx context.Context, state Board, n int) *MateNode {
+
+	for _, attack := range checksFirst(state) {
+		if ctx.Err() != nil {
+			return nil
+		}
+		//only a check can mate on the last move
+		if n == 1 && !attack.isChecked() {
+			break
+		}
+		if attack.winner == Stalemate {
+			continue
+		}
+
+		defences := attack.getPossibleMoves()
+		if len(defences) == 0 {
+			if attack.isChecked() {
+				return &MateNode{attack, []*MateNode{}}
+			}
+			continue
+		}
+		if n == 1 {
+			continue
+		}
+
+		node := &MateNode{attack, []*MateNode{}}
+		for _, defence := range defences {
+			answer := solveMate(ctx, *defence, n-1)
+			if answer == nil {
+				node = nil
+				break
+			}
+			node.children = append(node.children, &MateNode{defence, []*MateNode{answer}})
+		}
+		if node != nil {
+			return node
+		}
+	}
+	return nil
+}
+
+//checksFirst orders the moves from a board so that checks are tried before captures, and captures before quiet moves
+func checksFirst(state Board) []*Board {
+	ordered := append([]*Board{}, state.children...)
+	rank := func(child *Board) int {
+		if child.isChecked() {
+			return 0
+		}
+		if len(child.pieces) < len(state.pieces) {
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return rank(ordered[i]) < rank(ordered[j]) })
+	return ordered
+}
+
+//mateDepth is the number of attacking moves in a proven mate
+func (node *MateNode) mateDepth() int {
+	depth := 1
+	for _, defence := range node.children {
+		if answerDepth := defence.children[0].mateDepth() + 1; answerDepth > depth {
+			depth = answerDepth
+		}
+	}
+	return depth
+}
+
+//ToString writes out the solution tree, one move per line, with every defence indented under the attacking move
+//it answers
+func (node *MateNode) ToString() string {
+	return node.toString(0)
+}
+
+func (node *MateNode) toString(indent int) string {
+	out := strings.Repeat("  ", indent) + strings.TrimSpace(node.state.lastMoveString)
+	if len(node.children) == 0 {
+		out += "#"
+	}
+	out += "\n"
+	for _, defence := range node.children {
+		out += strings.Repeat("  ", indent+1) + "..." + strings.TrimSpace(defence.state.lastMoveString) + "\n"
+		out += defence.children[0].toString(indent + 2)
+	}
+	return out
+}
+
+func solveMateCommand(ctx context.Context, fen string, n int) {
+	state, err := BoardFromFEN(fen)
+	if err != nil {
+		print(err.Error() + "\n")
+		return
+	}
+
+	solution := solveMate(ctx, state, n)
+	if solution == nil && ctx.Err() != nil {
+		print("interrupted\n")
+		return
+	}
+	if solution == nil {
+		print(fmt.Sprintf("no forced mate in %d for %s\n", n, state.colourToMove))
+		return
+	}
+	print(fmt.Sprintf("%s mates in %d\n", state.colourToMove, solution.mateDepth()))
+	print(solution.ToString())
+}
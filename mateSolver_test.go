@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSolveMateInOne(t *testing.T) {
+	state := setUtilsTestBoardPosition()
+	solution := solveMate(context.Background(), state, 1)
+
+	if solution == nil {
+		t.Fatalf("expected mate in 1")
+	}
+	if solution.state.lastMoveString != "Qa7d7 " || len(solution.children) != 0 {
+		t.Errorf("expected Qa7d7# but got %s", solution.ToString())
+	}
+}
+
+func TestSolveMateInTwo(t *testing.T) {
+	//https://lichess.org/editor/r2qkb1r/pp2nppp/3p4/2pNN1B1/2BnP3/3P4/PPP2PPP/R2bK2R_w_KQkq_-_1_0
+	state, err := BoardFromFEN("r2qkb1r/pp2nppp/3p4/2pNN1B1/2BnP3/3P4/PPP2PPP/R2bK2R w KQkq - 1 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if solveMate(context.Background(), state, 1) != nil {
+		t.Errorf("found mate in 1 where there is none")
+	}
+
+	solution := solveMate(context.Background(), state, 2)
+	if solution == nil {
+		t.Fatalf("expected mate in 2")
+	}
+	if solution.mateDepth() != 2 {
+		t.Errorf("expected mate depth 2 but got %d", solution.mateDepth())
+	}
+	expected := "Nd5f6\n  ...Pg7f6\n    Bc4f7#\n"
+	if solution.ToString() != expected {
+		t.Errorf("expected solution\n%sbut got\n%s", expected, solution.ToString())
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if solveMate(cancelled, state, 2) != nil {
+		t.Errorf("a cancelled search proved a mate")
+	}
+}
+
+func TestSolveMateIgnoresStalemate(t *testing.T) {
+	//Qf7 stalemates, while Qd8 is mate
+	state, err := BoardFromFEN("7k/8/6K1/8/8/8/8/3Q4 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	solution := solveMate(context.Background(), state, 2)
+	if solution == nil || solution.state.lastMoveString != "Qd1d8 " {
+		t.Errorf("expected Qd1d8#")
+	}
+
+	stalemated := state.MakeMove(state.getSquare(3, 0), Vector{X: 5, Y: 6}, nil)
+	if solution := solveMate(context.Background(), stalemated, 2); solution != nil {
+		t.Errorf("expected no mate from a stalemated position but got %s", solution.ToString())
+	}
+}
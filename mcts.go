@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+//MCTSOptions tune the Monte Carlo tree search
+type MCTSOptions struct {
+	//Exploration is the PUCT constant: the higher it is, the more the search tries moves with few visits and high
+	//priors over the moves that have scored best so far
+	Exploration float64
+	//RolloutDepth is how many random moves are played from a new leaf before it is evaluated. 0 evaluates the leaf
+	//itself, which is what learned evaluators want
+	RolloutDepth int
+	//ValueScale is how many pawns of evaluation make a position about three quarters won. Evaluations are squashed
+	//into -1 to 1 with tanh(value / ValueScale)
+	ValueScale float64
+	//Temperature picks the move to play at random by visits to the power of 1 / Temperature. 0 plays the most visited
+	Temperature float64
+	//MultiPV is how many of the most visited root moves to give lines for. 0 is the same as 1
+	MultiPV int
+}
+
+var defaultMCTSOptions = MCTSOptions{
+	Exploration:  1.5,
+	RolloutDepth: 0,
+	ValueScale:   4,
+	Temperature:  0,
+	MultiPV:      1,
+}
+
+//defaultPlayouts is how many playouts a search without a node or time limit runs
+const defaultPlayouts = 800
+
+//firstMCTSInfo is the number of playouts of the first progress report. Later ones come whenever the playouts double
+const firstMCTSInfo = 64
+
+//MCTSNode is a position in the search tree. value is the sum of the playout results from the point of view of the
+//side that moved into the node, so the parent picks the child with the highest average
+type MCTSNode struct {
+	board    *Board
+	children []*MCTSNode
+	prior    float64
+	visits   int
+	value    float64
+	expanded bool
+	//terminal nodes end the game, or are in the tablebase, so always score terminalValue for the side to move
+	terminal      bool
+	terminalValue float64
+}
+
+//MCTSSearcher searches with Monte Carlo tree search, choosing which move to follow by PUCT as AlphaZero does. Leaves
+//are valued by evaluate, from white's point of view, after RolloutDepth random moves. priors gives the chance of each
+//move being best, and may be nil to treat every move the same. The tree is kept between searches, so the part of it
+//below the move played and the reply is searched again from where it was left
+type MCTSSearcher struct {
+	evaluate  func(board Board) float64
+	priors    func(board *Board, moves []*Board) []float64
+	tablebase Tablebase
+	options   MCTSOptions
+	random    *rand.Rand
+	root      *MCTSNode
+	//selDepth is the deepest ply a playout has reached this search
+	selDepth int
+	//onInfo is called as the search goes, whenever the number of playouts doubles
+	onInfo func(result SearchResult)
+}
+
+func newMCTSSearcher(evaluate func(board Board) float64, priors func(board *Board, moves []*Board) []float64, tablebase Tablebase) *MCTSSearcher {
+	return &MCTSSearcher{evaluate, priors, tablebase, defaultMCTSOptions, rand.New(rand.NewSource(0x3C75)), nil, 0, nil}
+}
+
+//think runs playouts from board until it has run limits.Nodes of them, its time runs out or ctx is cancelled, and
+//plays a move by the visits of the root's children. Without a node or time limit it runs defaultPlayouts. Depth
+//limits do not apply to the tree, which grows wherever the playouts go
+func (searcher *MCTSSearcher) think(ctx context.Context, board *Board, limits SearchLimits) SearchResult {
+	start := time.Now()
+	deadline := time.Time{}
+	if moveTime := limits.moveTime(); moveTime > 0 {
+		deadline = start.Add(moveTime)
+	}
+	maxPlayouts := limits.Nodes
+	if maxPlayouts <= 0 && deadline.IsZero() {
+		maxPlayouts = defaultPlayouts
+	}
+	searcher.root = searcher.reuse(board)
+	searcher.selDepth = 0
+
+	playouts := 0
+	for ctx.Err() == nil && (maxPlayouts <= 0 || playouts < maxPlayouts) {
+		//one playout always runs, so there is a move to play
+		if playouts > 0 && !deadline.IsZero() && time.Now().After(deadline) {
+			break
+		}
+		searcher.playout()
+		playouts++
+		if searcher.onInfo != nil && playouts >= firstMCTSInfo && playouts&(playouts-1) == 0 {
+			searcher.onInfo(searcher.result(playouts, time.Since(start), 0))
+		}
+	}
+	return searcher.result(playouts, time.Since(start), searcher.options.Temperature)
+}
+
+//reuse finds board in the tree from the last search, up to two plies below its root, so the playouts already spent on
+//it are kept. Anything else starts a new tree
+func (searcher *MCTSSearcher) reuse(board *Board) *MCTSNode {
+	if searcher.root != nil {
+		key := searchKeys.key(*board)
+		nodes := []*MCTSNode{searcher.root}
+		for ply := 0; ply <= 2; ply++ {
+			next := []*MCTSNode{}
+			for _, node := range nodes {
+				if !node.terminal && searchKeys.key(*node.board) == key {
+					return node
+				}
+				next = append(next, node.children...)
+			}
+			nodes = next
+		}
+	}
+	//the tree keeps its own copy, since the caller's board may be overwritten with the next position
+	root := *board
+	return &MCTSNode{&root, nil, 1, 0, 0, false, false, 0}
+}
+
+//playout walks down the tree by PUCT to a leaf, expands it and backs its value up the path
+func (searcher *MCTSSearcher) playout() {
+	node := searcher.root
+	path := []*MCTSNode{node}
+	for node.expanded && len(node.children) > 0 {
+		node = node.selectChild(searcher.options.Exploration)
+		path = append(path, node)
+	}
+	if len(path)-1 > searcher.selDepth {
+		searcher.selDepth = len(path) - 1
+	}
+
+	value := searcher.expand(node, node == searcher.root)
+	for i := len(path) - 1; i >= 0; i-- {
+		value = -value
+		path[i].visits++
+		path[i].value += value
+	}
+}
+
+//selectChild picks the child with the highest average value plus an exploration bonus, which is larger for children
+//with high priors and few visits
+func (node *MCTSNode) selectChild(exploration float64) *MCTSNode {
+	sqrtVisits := math.Sqrt(math.Max(float64(node.visits), 1))
+	var best *MCTSNode
+	bestScore := math.Inf(-1)
+	for _, child := range node.children {
+		score := exploration * child.prior * sqrtVisits / float64(1+child.visits)
+		if child.visits > 0 {
+			score += child.value / float64(child.visits)
+		}
+		if score > bestScore {
+			best, bestScore = child, score
+		}
+	}
+	return best
+}
+
+//expand adds a leaf's moves to the tree and returns its value for the side to move, between -1 and 1. Ends of games
+//and tablebase positions are not expanded, except at the root, which needs moves
+func (searcher *MCTSSearcher) expand(node *MCTSNode, root bool) float64 {
+	if node.terminal {
+		return node.terminalValue
+	}
+	board := node.board
+	if !root {
+		if board.winner == Stalemate {
+			return node.end(0)
+		}
+		if wdl, ok := probeTablebase(searcher.tablebase, *board); ok {
+			return node.end(tablebaseValue(wdl, board.colourToMove) * colourOf(board.colourToMove) / tablebaseWin)
+		}
+	}
+	moves := board.getPossibleMoves()
+	if len(moves) == 0 {
+		if board.isChecked() {
+			return node.end(-1)
+		}
+		return node.end(0)
+	}
+
+	priors := searcher.movePriors(board, moves)
+	node.children = make([]*MCTSNode, len(moves))
+	for i, move := range moves {
+		node.children[i] = &MCTSNode{move, nil, priors[i], 0, 0, false, false, 0}
+	}
+	node.expanded = true
+	return searcher.leafValue(*board)
+}
+
+//end marks a node as the end of the game, worth value to the side to move
+func (node *MCTSNode) end(value float64) float64 {
+	node.expanded = true
+	node.terminal = true
+	node.terminalValue = value
+	return value
+}
+
+//movePriors normalises the priors of the moves from board so they add up to 1, giving every move the same prior when
+//there is no prior function
+func (searcher *MCTSSearcher) movePriors(board *Board, moves []*Board) []float64 {
+	priors := make([]float64, len(moves))
+	total := 0.0
+	if searcher.priors != nil {
+		for i, prior := range searcher.priors(board, moves) {
+			if i < len(priors) && prior > 0 {
+				priors[i] = prior
+				total += prior
+			}
+		}
+	}
+	if total <= 0 {
+		for i := range priors {
+			priors[i] = 1
+		}
+		total = float64(len(priors))
+	}
+	for i := range priors {
+		priors[i] /= total
+	}
+	return priors
+}
+
+//leafValue plays up to RolloutDepth random moves from board and evaluates where they end, for the side to move on
+//board
+func (searcher *MCTSSearcher) leafValue(board Board) float64 {
+	colour := board.colourToMove
+	for i := 0; i < searcher.options.RolloutDepth; i++ {
+		if board.winner == Stalemate {
+			return 0
+		}
+		moves := board.getPossibleMoves()
+		if len(moves) == 0 {
+			if !board.isChecked() {
+				return 0
+			}
+			if board.colourToMove == colour {
+				return -1
+			}
+			return 1
+		}
+		board = *moves[searcher.random.Intn(len(moves))]
+	}
+	//the last random move can end the game, which evaluators do not score
+	if searcher.options.RolloutDepth > 0 && !board.hasPossibleBoardMoves() {
+		if !board.isChecked() {
+			return 0
+		}
+		if board.colourToMove == colour {
+			return -1
+		}
+		return 1
+	}
+	return math.Tanh(searcher.evaluate(board) * colourOf(colour) / searcher.options.ValueScale)
+}
+
+//result describes the tree as it stands after playouts, choosing the move at temperature
+func (searcher *MCTSSearcher) result(playouts int, elapsed time.Duration, temperature float64) SearchResult {
+	root := searcher.root
+	if len(root.children) == 0 {
+		value := Score(0)
+		if root.terminalValue < 0 {
+			value = matedAt(0)
+		}
+		return SearchResult{value, nil, nil, 0, 0, playouts, elapsed, 0, nil}
+	}
+
+	ranked := append([]*MCTSNode{}, root.children...)
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].visits > ranked[j].visits })
+	chosen := root.chooseChild(searcher.random, temperature)
+	lines := []SearchResult{searcher.line(chosen, playouts, elapsed)}
+	for _, child := range ranked {
+		if len(lines) >= searcher.options.MultiPV {
+			break
+		}
+		if child != chosen {
+			lines = append(lines, searcher.line(child, playouts, elapsed))
+		}
+	}
+	result := lines[0]
+	result.lines = lines
+	return result
+}
+
+//line follows the most visited children from the root move child, valuing it by its average playout result
+func (searcher *MCTSSearcher) line(child *MCTSNode, playouts int, elapsed time.Duration) SearchResult {
+	pv := []*Board{child.board}
+	for node := child; node.expanded && len(node.children) > 0; {
+		node = node.chooseChild(nil, 0)
+		if node.visits == 0 {
+			break
+		}
+		pv = append(pv, node.board)
+	}
+	return SearchResult{searcher.score(child), child.board, pv, len(pv), searcher.selDepth, playouts, elapsed, 0, nil}
+}
+
+//score turns a child's average playout result back into pawns, undoing the squashing of evaluations
+func (searcher *MCTSSearcher) score(child *MCTSNode) Score {
+	if child.visits == 0 {
+		return 0
+	}
+	average := math.Max(math.Min(child.value/float64(child.visits), 0.999), -0.999)
+	return Score(math.Atanh(average) * searcher.options.ValueScale)
+}
+
+//chooseChild picks a child by visits to the power of 1 / temperature, or the most visited one, with the higher prior
+//between equals, when temperature is 0
+func (node *MCTSNode) chooseChild(random *rand.Rand, temperature float64) *MCTSNode {
+	best := node.children[0]
+	for _, child := range node.children[1:] {
+		if child.visits > best.visits || child.visits == best.visits && child.prior > best.prior {
+			best = child
+		}
+	}
+	if temperature <= 0 || random == nil || best.visits == 0 {
+		return best
+	}
+	weights := make([]float64, len(node.children))
+	total := 0.0
+	for i, child := range node.children {
+		weights[i] = math.Pow(float64(child.visits)/float64(best.visits), 1/temperature)
+		total += weights[i]
+	}
+	choice := random.Float64() * total
+	for i, weight := range weights {
+		choice -= weight
+		if choice < 0 {
+			return node.children[i]
+		}
+	}
+	return best
+}
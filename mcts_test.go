@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+func TestMCTSFindsGoodMoves(t *testing.T) {
+	positions := []struct {
+		fen  string
+		move string
+	}{
+		{"4k3/8/8/3q4/8/8/8/3RK3 w - - 0 1", "Rd1d5"},
+		{"6k1/5ppp/8/8/8/8/8/1R4K1 w - - 0 1", "Rb1b8"},
+	}
+	for _, position := range positions {
+		board := mustFEN(t, position.fen)
+		result := newMCTSSearcher(verySimpleHeuristic, nil, nil).think(context.Background(), &board, SearchLimits{Nodes: 300})
+		if result.bestMove == nil || moveName(result.bestMove) != position.move {
+			t.Errorf("%s: expected %s but got %s", position.fen, position.move, pvString(result.pv))
+		}
+		if result.nodes != 300 || len(result.pv) == 0 || result.pv[0] != result.bestMove {
+			t.Errorf("%s: expected 300 playouts and a principal variation but got %d and %d moves", position.fen, result.nodes, len(result.pv))
+		}
+	}
+
+	//rollouts reach the same answer
+	board := mustFEN(t, positions[0].fen)
+	searcher := newMCTSSearcher(verySimpleHeuristic, nil, nil)
+	searcher.options.RolloutDepth = 2
+	if result := searcher.think(context.Background(), &board, SearchLimits{Nodes: 300}); moveName(result.bestMove) != "Rd1d5" {
+		t.Errorf("with rollouts expected Rd1d5 but got %s", moveName(result.bestMove))
+	}
+}
+
+func TestMCTSPriors(t *testing.T) {
+	board := NewBoard()
+	//a prior on one move makes the search spend most of its playouts on it
+	priors := func(board *Board, moves []*Board) []float64 {
+		values := make([]float64, len(moves))
+		for i, move := range moves {
+			values[i] = 0.01
+			if moveName(move) == "Pe2e4" {
+				values[i] = 1
+			}
+		}
+		return values
+	}
+	result := newMCTSSearcher(verySimpleHeuristic, priors, nil).think(context.Background(), &board, SearchLimits{Nodes: 100})
+	if moveName(result.bestMove) != "Pe2e4" {
+		t.Errorf("expected the move with the prior but got %s", moveName(result.bestMove))
+	}
+}
+
+func TestMCTSReusesTree(t *testing.T) {
+	board := NewBoard()
+	searcher := newMCTSSearcher(verySimpleHeuristic, nil, nil)
+	result := searcher.think(context.Background(), &board, SearchLimits{Nodes: 200})
+	reply := searcher.root.chooseChild(nil, 0).chooseChild(nil, 0)
+	if reply.visits == 0 {
+		t.Fatalf("the expected reply was never visited")
+	}
+	kept := reply.visits
+
+	next := *reply.board
+	searcher.think(context.Background(), &next, SearchLimits{Nodes: 50})
+	if searcher.root != reply || searcher.root.visits != kept+50 {
+		t.Errorf("expected the tree below %s to be kept with %d visits but the root has %d", pvString(result.pv[:2]), kept+50, searcher.root.visits)
+	}
+
+	other := mustFEN(t, "4k3/8/8/3q4/8/8/8/3RK3 w - - 0 1")
+	searcher.think(context.Background(), &other, SearchLimits{Nodes: 10})
+	if searcher.root.visits != 10 {
+		t.Errorf("a new position should start a new tree but its root has %d visits", searcher.root.visits)
+	}
+	//callers reuse their board for the next position, which must not change the tree
+	other = NewBoard()
+	if searcher.root.board.ToFEN() == other.ToFEN() {
+		t.Errorf("the root of the tree changed with the caller's board")
+	}
+}
+
+func TestMCTSTemperature(t *testing.T) {
+	node := &MCTSNode{children: []*MCTSNode{{visits: 10, prior: 0.2}, {visits: 30, prior: 0.1}, {visits: 0, prior: 0.7}}}
+	if node.chooseChild(nil, 0) != node.children[1] {
+		t.Errorf("temperature 0 should pick the most visited child")
+	}
+	random := rand.New(rand.NewSource(1))
+	picks := map[*MCTSNode]int{}
+	for i := 0; i < 1000; i++ {
+		picks[node.chooseChild(random, 1)]++
+	}
+	//visits to the power of 1 give the first child a quarter of the picks
+	if picks[node.children[2]] != 0 || picks[node.children[0]] < 200 || picks[node.children[0]] > 300 {
+		t.Errorf("expected picks in proportion to visits but got %d, %d and %d", picks[node.children[0]], picks[node.children[1]], picks[node.children[2]])
+	}
+}
+
+func TestMCTSPlaysInTheArena(t *testing.T) {
+	board := NewBoard()
+	searchers := []Searcher{newMCTSSearcher(verySimpleHeuristic, nil, nil), newAlphaBetaSearcher(verySimpleHeuristic, nil, nil)}
+	for _, searcher := range searchers {
+		move := chooseMove(context.Background(), &board, searcher, SearchLimits{Depth: 1, Nodes: 20}, nil, rand.New(rand.NewSource(1)), 0)
+		if move == nil {
+			t.Errorf("%T did not choose a move", searcher)
+			continue
+		}
+		board = *move
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if result := newMCTSSearcher(verySimpleHeuristic, nil, nil).think(cancelled, &board, SearchLimits{}); result.nodes != 0 {
+		t.Errorf("a cancelled search ran %d playouts", result.nodes)
+	}
+}
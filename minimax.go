@@ -1,226 +1,100 @@
 package main
 
-import (
-	"math"
-	"sort"
-)
-
-type Strategy string
-
-const (
-	//Player1
-	Player1 Strategy = "Player1"
-	//Player2
-	Player2 Strategy = "Player2"
-)
-
-//MinimaxTree is a min-max tree of possible future moves
-type MinimaxTree struct {
-	children      []*MinimaxTree
-	value         float64
-	height        int
-	depth         int
-	isMaximising  bool
-	state         *Board
-	nextMove      *Board
-	nextMoveIndex int
-	worstSibling  *float64
-	bestChild     *float64
-	strategy      Strategy
+//Tablebase gives exact results for the positions it covers, from the side to move's point of view
+type Tablebase interface {
+	ProbeWDL(board Board) (WDL, bool)
 }
 
-func createRoot(height int, colour Colour, state *Board, strategy Strategy, config1, config2 Policy) MinimaxTree {
-
-	heurConfig1 := config1.HeauristicConfig.unmarshalJson()
-	heurConfig2 := config2.HeauristicConfig.unmarshalJson()
-
-	root := MinimaxTree{
-		children:     []*MinimaxTree{},
-		value:        0.0,
-		height:       height,
-		depth:        0,
-		isMaximising: colour == White,
-		state:        state,
-		nextMove:     nil,
-		worstSibling: nil,
-		bestChild:    nil,
-		strategy:     strategy,
-	}
-
-	moveValue := 0.0
-	currentMove := root.state
-
-	for i, nextState := range root.state.children {
-		child := newMinimaxTree(*nextState, &root, &heurConfig1, &heurConfig2)
-		root.children = append(root.children, &child)
-		if i == 0 || (child.value > moveValue && root.isMaximising) || (child.value < moveValue && !root.isMaximising) {
-			moveValue = child.value
-			currentMove = child.state
-		}
+//probeTablebase probes tablebase, which may be nil
+func probeTablebase(tablebase Tablebase, board Board) (WDL, bool) {
+	if tablebase == nil {
+		return WDLDraw, false
 	}
-	root.nextMove = currentMove
-	return root
+	return tablebase.ProbeWDL(board)
 }
 
-func newMinimaxTree(boardState Board, parent *MinimaxTree, config1 *PieceValueConfig, config2 *PieceValueConfig) MinimaxTree {
-	boardState.children = boardState.getPossibleMoves()
+//tablebaseWin is the value of a tablebase win. It is above any material balance but below a checkmate, so the
+//search still takes a mate when it can see one
+const tablebaseWin = 100000.0
 
-	children := []*MinimaxTree{}
-	depth := parent.depth + 1
-	isMaximising := !parent.isMaximising
+//tablebaseValue converts a tablebase result for the side to move into a value from white's point of view. Results
+//spoilt by the fifty move rule are draws
+func tablebaseValue(wdl WDL, colourToMove Colour) float64 {
 	value := 0.0
-	worstSibling := math.Inf(1)
-	bestChild := math.Inf(-1)
-
-	if !isMaximising {
-		worstSibling = math.Inf(-1)
-		bestChild = math.Inf(1)
+	if wdl == WDLWin {
+		value = tablebaseWin
 	}
-
-	if parent.bestChild != nil {
-		worstSibling = *parent.bestChild
+	if wdl == WDLLoss {
+		value = -tablebaseWin
 	}
-
-	if depth == parent.height {
-		//switch out heuristic here
-		switch parent.strategy {
-		case Player1:
-			value = verySimpleHeuristic(boardState)
-			// value = generalHeuristic(&boardState, config1)
-		case Player2:
-			value = verySimpleHeuristic(boardState)
-			// value = generalHeuristic(&boardState, config2)
-		default:
-			value = verySimpleHeuristic(boardState)
-		}
-
-		return MinimaxTree{children, value, parent.height, depth, isMaximising, &boardState, nil, -1, &worstSibling, nil, parent.strategy}
-	}
-
-	tree := MinimaxTree{children, value, parent.height, depth, isMaximising, &boardState, nil, -1, &worstSibling, nil, parent.strategy}
-
-	//try to sort moves to calculate by most promising
-	rankedStates := map[float64]*Board{}
-	colourMult := 1.0
-	if boardState.colourToMove == Black {
-		colourMult = -1.0
-	}
-	for _, nextState := range boardState.children {
-		rankedStates[verySimpleHeuristic(*nextState)*colourMult] = nextState
-	}
-
-	keys := []float64{}
-	for key := range rankedStates {
-		keys = append(keys, key)
-	}
-	sort.Float64s(keys)
-
-	for _, key := range keys {
-		nextState := rankedStates[key]
-
-		child := newMinimaxTree(*nextState, &tree, config1, config2)
-		if isMaximising {
-			bestChild = math.Max(child.value, bestChild)
-		} else {
-			bestChild = math.Min(child.value, bestChild)
-		}
-		tree.bestChild = &bestChild
-
-		children = append(children, &child)
-		if isMaximising {
-			if bestChild >= worstSibling {
-				break
-			}
-		} else {
-			if bestChild <= worstSibling {
-				break
-			}
-		}
-
-	}
-
-	if len(children) == 0 {
-		if boardState.isBlackCheckmated() {
-			return MinimaxTree{children, math.Inf(-1), parent.height, depth, isMaximising, &boardState, nil, -1, &worstSibling, nil, parent.strategy}
-		}
-		if boardState.isWhiteCheckmated() {
-			return MinimaxTree{children, math.Inf(1), parent.height, depth, isMaximising, &boardState, nil, -1, &worstSibling, nil, parent.strategy}
-		}
-		if boardState.isStalemate() {
-			return MinimaxTree{children, 0, parent.height, depth, isMaximising, &boardState, nil, -1, &worstSibling, nil, parent.strategy}
-		}
-		print("minimax error: no child trees\n")
+	if colourToMove == Black {
+		return -value
 	}
+	return value
+}
 
-	bestValue := children[0].value
-	bestMove := children[0].state
-	tree.nextMoveIndex = 0
-	if len(children) > 1 {
-		if isMaximising {
-			for i, child := range children[1:] {
-				if child.value > bestValue {
-					bestValue = child.value
-					bestMove = child.state
-					tree.nextMoveIndex = i
-				}
-			}
-		} else {
-			for i, child := range children[1:] {
-				if child.value < bestValue {
-					bestValue = child.value
-					bestMove = child.state
-					tree.nextMoveIndex = i
-				}
-			}
-		}
+func verySimpleHeuristic(board Board) float64 {
+	if value, over := gameOverValue(board); over {
+		return value
 	}
-
-	tree.value = bestValue
-	tree.nextMove = bestMove
-	return tree
+	return materialValue(board)
 }
 
-func verySimpleHeuristic(board Board) float64 {
+//gameOverValue scores boards where the game has ended from white's point of view, and is false for the rest
+func gameOverValue(board Board) (float64, bool) {
 	if board.isStalemate() {
-		return 0
+		return 0, true
 	}
 	if board.isBlackCheckmated() {
-		return math.Inf(1)
+		return float64(mateScore), true
 	}
 	if board.isWhiteCheckmated() {
-		return math.Inf(-1)
+		return -float64(mateScore), true
 	}
+	return 0, false
+}
+
+//materialValue counts material from white's point of view, with pawns worth more as they advance
+func materialValue(board Board) float64 {
 	total := 0.0
 	for _, piece := range board.pieces {
 		colourMult := 1.0
 		if piece.colour == Black {
 			colourMult = -1
 		}
-		nextPieceValue := 0.0
+		nextPieceValue := simplePieceValue(piece.pieceType.sign)
 		if piece.pieceType.sign == "P" {
-			nextPieceValue = 1.0
 			if piece.colour == White {
 				nextPieceValue += float64(piece.position.Y) * 0.1
 			} else {
 				nextPieceValue += (7.0 - float64(piece.position.Y)) * 0.1
 			}
 		}
-		if piece.pieceType.sign == "N" {
-			nextPieceValue = 3.0
-		}
-		if piece.pieceType.sign == "B" {
-			nextPieceValue = 3.25
-		}
-		if piece.pieceType.sign == "R" {
-			nextPieceValue = 5.0
-		}
-		if piece.pieceType.sign == "Q" {
-			nextPieceValue = 9.0
-		}
-		if piece.pieceType.sign == "K" {
-			nextPieceValue = 1000000.0
-		}
 		total += nextPieceValue * colourMult
 	}
+	for sign, count := range board.whitePocket {
+		total += simplePieceValue(sign) * float64(count)
+	}
+	for sign, count := range board.blackPocket {
+		total -= simplePieceValue(sign) * float64(count)
+	}
 	return total
 }
+
+func simplePieceValue(sign string) float64 {
+	switch sign {
+	case "P":
+		return 1.0
+	case "N":
+		return 3.0
+	case "B":
+		return 3.25
+	case "R":
+		return 5.0
+	case "Q":
+		return 9.0
+	case "K":
+		return 1000000.0
+	}
+	return 0
+}
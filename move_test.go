@@ -1,6 +1,9 @@
 package main
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func createBoardLayout(pieces []*Piece, enPassantRank int, castleBK bool, castleBQ bool, castleWK bool, castleWQ bool, colourToMove Colour) Board {
 	squares := make([][]*Piece, 8)
@@ -194,3 +197,234 @@ func TestCannotMoveIntoCheck(t *testing.T) {
 		}
 	}
 }
+
+func TestEnPassantRemovesTakenPawn(t *testing.T) {
+	startPosition := createBoardLayout([]*Piece{
+		{king, White, Vector{X: 4, Y: 0}},
+		{pawn, White, Vector{X: 4, Y: 4}},
+		{king, Black, Vector{X: 4, Y: 7}},
+		{pawn, Black, Vector{X: 3, Y: 6}},
+	}, -1, false, false, false, false, Black)
+	pushed := startPosition.MakeMove(startPosition.pieces[3], Vector{X: 3, Y: 4}, nil)
+	taken := pushed.MakeMove(pushed.getSquare(4, 4), Vector{X: 3, Y: 5}, nil)
+
+	if len(taken.pieces) != 3 || taken.getSquare(3, 4) != nil {
+		t.Errorf("pawn taken en passant is still on the board")
+	}
+	if pawn := taken.getSquare(3, 5); pawn == nil || pawn.colour != White {
+		t.Errorf("pawn taking en passant has not moved")
+	}
+}
+
+func TestEnPassantOnlyTakesFromTheFifthRank(t *testing.T) {
+	//black's d pawn is doubled, with its second pawn beside white's c pawn on the third rank
+	startPosition := createBoardLayout([]*Piece{
+		{king, White, Vector{X: 7, Y: 0}},
+		{pawn, White, Vector{X: 2, Y: 2}},
+		{king, Black, Vector{X: 7, Y: 7}},
+		{pawn, Black, Vector{X: 3, Y: 6}},
+		{pawn, Black, Vector{X: 3, Y: 2}},
+	}, -1, false, false, false, false, Black)
+	pushed := startPosition.MakeMove(startPosition.pieces[3], Vector{X: 3, Y: 4}, nil)
+
+	for _, move := range pushed.getSquare(2, 2).getPossibleMoves(pushed) {
+		if (move == Vector{X: 3, Y: 3}) {
+			t.Errorf("pawn off its fifth rank can take en passant")
+		}
+	}
+}
+
+func TestCrazyhouseCaptureFillsPocket(t *testing.T) {
+	state, err := BoardFromFEN("4k3/8/8/3p4/4P3/8/8/4K3[] w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextState := state.MakeMove(state.getSquare(4, 3), Vector{X: 3, Y: 4}, nil)
+
+	if nextState.whitePocket["P"] != 1 {
+		t.Errorf("expected captured pawn in white pocket but pocket is %v", nextState.whitePocket)
+	}
+	if state.whitePocket["P"] != 0 {
+		t.Errorf("capture changed the pocket of the previous board")
+	}
+}
+
+func TestCrazyhousePromotedPieceRevertsToPawn(t *testing.T) {
+	state, err := BoardFromFEN("3Q~k3/8/8/8/8/8/8/4K3[] b - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextState := state.MakeMove(state.getSquare(4, 7), Vector{X: 3, Y: 7}, nil)
+
+	if nextState.blackPocket["P"] != 1 || nextState.blackPocket["Q"] != 0 {
+		t.Errorf("expected captured promoted queen to be pocketed as a pawn but pocket is %v", nextState.blackPocket)
+	}
+}
+
+func TestCrazyhouseDrops(t *testing.T) {
+	state, err := BoardFromFEN("4k3/8/8/8/8/8/8/4K3[NP] w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drops := map[string]bool{}
+	for _, child := range state.getPossibleMoves() {
+		if strings.Contains(child.lastMoveString, "@") {
+			drops[strings.TrimSpace(child.lastMoveString)] = true
+		}
+	}
+
+	if !drops["N@f3"] {
+		t.Errorf("expected knight drop N@f3")
+	}
+	if !drops["N@a8"] {
+		t.Errorf("expected knight drop onto the back rank")
+	}
+	if drops["P@a8"] || drops["P@a1"] {
+		t.Errorf("pawns cannot be dropped on the first or last rank")
+	}
+	if len(drops) != 62+48 {
+		t.Errorf("expected %d drops but got %d", 62+48, len(drops))
+	}
+}
+
+func TestCrazyhouseDropBlocksMate(t *testing.T) {
+	//the back rank mate can be blocked by dropping the knight anywhere between the rook and king
+	state, err := BoardFromFEN("R5k1/5ppp/8/8/8/8/8/6K1[n] b - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state.isBlackCheckmated() {
+		t.Errorf("black can block check with a drop so is not checkmated")
+	}
+	if len(state.getPossibleMoves()) != 5 {
+		t.Errorf("expected only the 5 blocking drops but got %d moves", len(state.getPossibleMoves()))
+	}
+}
+
+func TestEnPassantCaptureRemovesPawn(t *testing.T) {
+	state, err := BoardFromFEN("rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextState := state.MakeMove(state.getSquare(3, 3), Vector{X: 4, Y: 2}, nil)
+
+	if nextState.getSquare(4, 3) != nil || len(nextState.pieces) != 31 {
+		t.Errorf("pawn taken en passant has not been removed")
+	}
+}
+
+func TestCastlingMovesKingAndRook(t *testing.T) {
+	tests := []struct {
+		fen                string
+		king, rook, corner Vector
+	}{
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", Vector{X: 6, Y: 0}, Vector{X: 5, Y: 0}, Vector{X: 7, Y: 0}},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", Vector{X: 2, Y: 0}, Vector{X: 3, Y: 0}, Vector{X: 0, Y: 0}},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", Vector{X: 6, Y: 7}, Vector{X: 5, Y: 7}, Vector{X: 7, Y: 7}},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", Vector{X: 2, Y: 7}, Vector{X: 3, Y: 7}, Vector{X: 0, Y: 7}},
+	}
+	for _, test := range tests {
+		state, err := BoardFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		castled := false
+		for _, move := range state.getPossibleMoves() {
+			if king := move.getSquare(test.king.X, test.king.Y); king == nil || king.pieceType.sign != "K" {
+				continue
+			}
+			castled = true
+			if rook := move.getSquare(test.rook.X, test.rook.Y); rook == nil || rook.pieceType.sign != "R" || rook.position != test.rook || move.getSquare(test.corner.X, test.corner.Y) != nil {
+				t.Errorf("castling to %s in %s did not move the rook beside the king", test.king.toString(), test.fen)
+			}
+		}
+		if !castled {
+			t.Errorf("cannot castle to %s in %s", test.king.toString(), test.fen)
+		}
+	}
+}
+
+func TestCannotCastleThroughCheck(t *testing.T) {
+	state, err := BoardFromFEN("4k3/8/8/8/8/8/5r2/4K2R w K - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, move := range state.getSquare(4, 0).getPossibleMoves(state) {
+		if (move == Vector{X: 6, Y: 0}) {
+			t.Errorf("can castle through a covered square")
+		}
+	}
+}
+
+func TestBlackPawnsPromoteOnTheFirstRank(t *testing.T) {
+	//black's king is boxed in, so pushing the pawn to promote is black's only move
+	state, err := BoardFromFEN("7k/5Q2/8/8/8/8/4p3/K7 b - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pawn := state.getSquare(4, 1); !pawn.hasPossibleMoves(state) {
+		t.Errorf("pawn on the second rank has no moves")
+	}
+	promotions := 0
+	for _, move := range state.getPossibleMoves() {
+		if piece := move.getSquare(4, 0); piece != nil && piece.colour == Black {
+			promotions++
+		}
+	}
+	if promotions != 4 {
+		t.Errorf("expected 4 promotions but found %d", promotions)
+	}
+}
+
+func perft(state Board, depth int) int {
+	if depth == 0 {
+		return 1
+	}
+	total := 0
+	for _, child := range state.getPossibleMoves() {
+		total += perft(*child, depth-1)
+	}
+	return total
+}
+
+//https://www.chessprogramming.org/Perft_Results
+func TestPerft(t *testing.T) {
+	tests := []struct {
+		fen   string
+		depth int
+		nodes int
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 3, 8902},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 2, 2039},
+		{"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", 3, 2812},
+		{"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", 2, 264},
+		{"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", 2, 1486},
+	}
+	for _, test := range tests {
+		state, err := BoardFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nodes := perft(state, test.depth); nodes != test.nodes {
+			t.Errorf("%s has %d positions at depth %d but should have %d", test.fen, nodes, test.depth, test.nodes)
+		}
+	}
+}
+
+func TestEachPromotionIsItsOwnMove(t *testing.T) {
+	state, err := BoardFromFEN("7k/4P3/8/8/8/8/8/K7 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	promotions := map[string]bool{}
+	for _, move := range state.getPossibleMoves() {
+		if piece := move.getSquare(4, 7); piece != nil {
+			promotions[piece.pieceType.sign] = true
+		}
+	}
+	if len(promotions) != 4 {
+		t.Errorf("expected promotions to 4 different pieces but found %v", promotions)
+	}
+}
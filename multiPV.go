@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+//lineCount is how many lines a search of board finds: MultiPV of them, or every move when there are fewer, and
+//always at least one so a board without moves still gets a value
+func (searcher *AlphaBetaSearcher) lineCount(board *Board) int {
+	count := searcher.options.MultiPV
+	if count <= 1 {
+		return 1
+	}
+	if moves := len(board.getPossibleMoves()); moves < count {
+		count = moves
+	}
+	if count < 1 {
+		return 1
+	}
+	return count
+}
+
+//searchLines searches board to depth count times, each time leaving out the root moves already given a line, so
+//each line is the best of the moves left with an exact value from a full window. It returns nil when the search
+//stopped before every line was found
+func (searcher *AlphaBetaSearcher) searchLines(board *Board, depth, count int, start time.Time) []SearchResult {
+	searcher.excluded = map[string]bool{}
+	defer func() { searcher.excluded = nil }()
+
+	lines := []SearchResult{}
+	for len(lines) < count {
+		value, pv := searcher.negamax(board, depth, 0, -infiniteScore, infiniteScore)
+		if searcher.stopped {
+			return nil
+		}
+		if len(pv) == 0 {
+			lines = append(lines, SearchResult{value, nil, pv, depth, 0, 0, 0, 0, nil})
+			break
+		}
+		lines = append(lines, SearchResult{value, pv[0], pv, depth, 0, 0, 0, 0, nil})
+		searcher.excluded[moveName(pv[0])] = true
+	}
+	for i := range lines {
+		lines[i].selDepth = searcher.selDepth
+		lines[i].nodes = searcher.nodes
+		lines[i].elapsed = time.Since(start)
+		lines[i].firstMoveCutoffRate = searcher.orderer.firstMoveCutoffRate()
+	}
+	return lines
+}
+
+//withoutExcluded leaves out the root moves that already have a line
+func (searcher *AlphaBetaSearcher) withoutExcluded(moves []*Board) []*Board {
+	kept := []*Board{}
+	for _, move := range moves {
+		if !searcher.excluded[moveName(move)] {
+			kept = append(kept, move)
+		}
+	}
+	return kept
+}
+
+//pickMove chooses one of the result's lines at random, so self-play games differ. Each line is weighted by e to the
+//power of how many pawns it is below the best over temperature, and a temperature of 0 always plays the best move
+func (result SearchResult) pickMove(random *rand.Rand, temperature float64) *Board {
+	if temperature <= 0 || len(result.lines) <= 1 {
+		return result.bestMove
+	}
+	weights := []float64{}
+	total := 0.0
+	for _, line := range result.lines {
+		weight := math.Exp(float64(line.value-result.value) / temperature)
+		weights = append(weights, weight)
+		total += weight
+	}
+	choice := random.Float64() * total
+	for i, weight := range weights {
+		choice -= weight
+		if choice < 0 {
+			return result.lines[i].bestMove
+		}
+	}
+	return result.bestMove
+}
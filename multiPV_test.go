@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+func TestMultiPV(t *testing.T) {
+	board := mustFEN(t, "r1bqkbnr/pppp1ppp/2n5/4p3/3PP3/8/PPP2PPP/RNBQKBNR w KQkq - 0 3")
+	searcher := newAlphaBetaSearcher(verySimpleHeuristic, nil, newTranspositionTable(1))
+	//minimax gives the value of every root move, so selective search is left out
+	searcher.options = SearchOptions{MultiPV: 3}
+	result := searcher.search(context.Background(), &board, 2)
+	if len(result.lines) != 3 {
+		t.Fatalf("expected 3 lines but got %d", len(result.lines))
+	}
+	if result.lines[0].bestMove != result.bestMove || result.lines[0].value != result.value {
+		t.Errorf("the first line %s is not the result %s", result.lines[0], result)
+	}
+
+	values := map[string]Score{}
+	for _, child := range board.getPossibleMoves() {
+		values[moveName(child)] = -plainMinimax(child, 1, 1, verySimpleHeuristic)
+	}
+	played := map[string]bool{}
+	for i, line := range result.lines {
+		move := moveName(line.bestMove)
+		if played[move] {
+			t.Errorf("%s has two lines", move)
+		}
+		played[move] = true
+		if line.value != values[move] {
+			t.Errorf("line %d: %s is worth %s but minimax gives %s", i+1, move, line.value, values[move])
+		}
+		if i > 0 && line.value > result.lines[i-1].value {
+			t.Errorf("line %d is better than the line before it", i+1)
+		}
+	}
+	for move, value := range values {
+		if !played[move] && value > result.lines[2].value {
+			t.Errorf("%s is worth %s, more than the third line", move, value)
+		}
+	}
+
+	//a king with one move has one line
+	board = mustFEN(t, "7k/8/6K1/8/8/8/8/R7 b - - 0 1")
+	if result := searcher.search(context.Background(), &board, 2); len(result.lines) != 1 || result.bestMove == nil {
+		t.Errorf("expected one line but got %d", len(result.lines))
+	}
+}
+
+func TestPickMove(t *testing.T) {
+	board := mustFEN(t, "r5k1/5ppp/8/8/8/8/1Q6/1R4K1 w - - 0 1")
+	searcher := newAlphaBetaSearcher(verySimpleHeuristic, nil, nil)
+	searcher.options.MultiPV = 4
+	result := searcher.search(context.Background(), &board, 3)
+	random := rand.New(rand.NewSource(1))
+
+	if move := result.pickMove(random, 0); move != result.bestMove {
+		t.Errorf("temperature 0 picked %s", moveName(move))
+	}
+	//mating is worth far more than anything else, so is always picked
+	for i := 0; i < 20; i++ {
+		if move := result.pickMove(random, 1); moveName(move) != "Qb2b8" {
+			t.Errorf("picked %s over the mate", moveName(move))
+		}
+	}
+
+	//the other lines are all worth the same, so each gets picked
+	result.lines = result.lines[1:]
+	result.value = result.lines[0].value
+	picked := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		picked[moveName(result.pickMove(random, 1))] = true
+	}
+	if len(picked) != 3 {
+		t.Errorf("expected all 3 equal moves to be picked but got %v", picked)
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+//GameRecord is a finished game, with its moves named as in moveName
+type GameRecord struct {
+	White  string   `json:"white"`
+	Black  string   `json:"black"`
+	Result WinState `json:"result"`
+	Moves  []string `json:"moves"`
+}
+
+//OpeningNode is a position in an opening tree, with the results of the games that reached it and the moves played
+//from it
+type OpeningNode struct {
+	Games     int                     `json:"games"`
+	WhiteWins int                     `json:"whiteWins"`
+	Draws     int                     `json:"draws"`
+	BlackWins int                     `json:"blackWins"`
+	Moves     map[string]*OpeningNode `json:"moves,omitempty"`
+}
+
+//OpeningBookOptions control which games go into an opening tree and which moves are kept
+type OpeningBookOptions struct {
+	MaxPly   int
+	MinGames int
+	//Results keeps only games with these results, or every game when empty
+	Results []WinState
+	//Players keeps only games where one of these policies played, or every game when empty
+	Players []string
+}
+
+//moveName is how game records write the move that led to a board, such as "Pe2e4", "O-O" or "Pe7e8=Q"
+func moveName(board *Board) string {
+	return strings.Replace(board.lastMoveString, " ", "", -1)
+}
+
+//playMoveName finds the move with the given name from a board
+func (boardState Board) playMoveName(name string) *Board {
+	for _, child := range boardState.getPossibleMoves() {
+		if moveName(child) == name {
+			return child
+		}
+	}
+	return nil
+}
+
+//playSAN finds the move written in standard algebraic notation, such as "Nbd7", "exd5", "e8=Q+" or "O-O", from a
+//board. It returns nil when the move is illegal or ambiguous
+func (boardState Board) playSAN(san string) *Board {
+	san = strings.TrimRight(san, "+#!?")
+	san = strings.Replace(san, "0", "O", -1)
+	if san == "O-O" || san == "O-O-O" {
+		return boardState.playMoveName(san)
+	}
+
+	promotion := ""
+	if split := strings.Index(san, "="); split >= 0 {
+		promotion, san = san[split+1:], san[:split]
+	} else if len(san) > 2 && strings.ContainsAny(san[len(san)-1:], "QRBN") && strings.ContainsAny(san[len(san)-2:len(san)-1], "18") {
+		promotion, san = san[len(san)-1:], san[:len(san)-1]
+	}
+
+	sign := "P"
+	if len(san) > 0 && strings.ContainsAny(san[:1], "KQRBN") {
+		sign, san = san[:1], san[1:]
+	}
+	san = strings.Replace(san, "x", "", -1)
+	if len(san) < 2 {
+		return nil
+	}
+	to, from := san[len(san)-2:], san[:len(san)-2]
+
+	if promotion != "" {
+		promotion = "=" + promotion
+	}
+
+	var found *Board
+	for _, child := range boardState.getPossibleMoves() {
+		name := moveName(child)
+		if len(name) < 5 || name[:1] != sign || name[3:5] != to || name[5:] != promotion {
+			continue
+		}
+		if len(from) > 0 && !strings.Contains(name[1:3], from[:1]) || len(from) > 1 && name[1:3] != from {
+			continue
+		}
+		if found != nil {
+			return nil
+		}
+		found = child
+	}
+	return found
+}
+
+var pgnComments = regexp.MustCompile(`\{[^}]*\}|;[^\n]*|\$\d+`)
+var pgnTag = regexp.MustCompile(`^\[(\w+)\s+"(.*)"\]$`)
+var pgnMoveNumber = regexp.MustCompile(`^\d+\.+`)
+
+//pgnResults map PGN result tokens to results
+var pgnResults = map[string]WinState{"1-0": WhiteWon, "0-1": BlackWon, "1/2-1/2": Stalemate, "*": Undecided}
+
+//readPGN reads the games in a PGN file, playing at most maxPly moves of each. Variations and comments are skipped, and
+//so are games with a move that cannot be played
+func readPGN(pgn string, maxPly int) []GameRecord {
+	games := []GameRecord{}
+	var game *GameRecord
+	var board Board
+	tokens := []string{}
+	skipped := 0
+
+	//finishGame plays the game's moves, leaving it out with a message when one of them cannot be played
+	finishGame := func() {
+		if game == nil {
+			return
+		}
+		legal := true
+		for _, token := range tokens {
+			if len(game.Moves) >= maxPly {
+				break
+			}
+			next := board.playSAN(token)
+			if next == nil {
+				print(fmt.Sprintf("skipping game %d, %s is not a legal move in %s\n", len(games)+skipped+1, token, board.ToFEN()))
+				legal = false
+				break
+			}
+			game.Moves = append(game.Moves, moveName(next))
+			board = *next
+		}
+		if legal {
+			games = append(games, *game)
+		} else {
+			skipped++
+		}
+		game = nil
+		tokens = []string{}
+	}
+
+	variationDepth := 0
+	for _, line := range strings.Split(pgnComments.ReplaceAllString(pgn, " "), "\n") {
+		line = strings.TrimSpace(line)
+		if tag := pgnTag.FindStringSubmatch(line); tag != nil {
+			if game != nil && len(tokens) > 0 {
+				finishGame()
+			}
+			if game == nil {
+				game = &GameRecord{Result: Undecided, Moves: []string{}}
+				board = NewBoard()
+			}
+			switch tag[1] {
+			case "White":
+				game.White = tag[2]
+			case "Black":
+				game.Black = tag[2]
+			case "Result":
+				game.Result = pgnResults[tag[2]]
+			}
+			continue
+		}
+
+		line = strings.Replace(strings.Replace(line, "(", " ( ", -1), ")", " ) ", -1)
+		for _, token := range strings.Fields(line) {
+			switch {
+			case token == "(":
+				variationDepth++
+			case token == ")":
+				variationDepth--
+			case variationDepth > 0:
+			case pgnResults[token] != "":
+				if game != nil && game.Result == Undecided {
+					game.Result = pgnResults[token]
+				}
+				finishGame()
+			default:
+				if token = pgnMoveNumber.ReplaceAllString(token, ""); token != "" {
+					if game == nil {
+						game = &GameRecord{Result: Undecided, Moves: []string{}}
+						board = NewBoard()
+					}
+					tokens = append(tokens, token)
+				}
+			}
+		}
+	}
+	finishGame()
+	return games
+}
+
+func writeGameRecords(records []GameRecord, fileName string, dir string) {
+	fileJson, err := json.Marshal(records)
+	if err != nil {
+		print("error")
+	}
+	_ = ioutil.WriteFile(dir+fileName+".json", fileJson, 0644)
+}
+
+func readGameRecords(fileName, dir string) []GameRecord {
+	file, err := ioutil.ReadFile(dir + fileName)
+
+	if err != nil {
+		print(err)
+	}
+
+	records := []GameRecord{}
+
+	_ = json.Unmarshal(file, &records)
+
+	return records
+}
+
+func (options OpeningBookOptions) keeps(game GameRecord) bool {
+	keep := len(options.Results) == 0
+	for _, result := range options.Results {
+		if game.Result == result {
+			keep = true
+		}
+	}
+	if !keep || len(options.Players) == 0 {
+		return keep
+	}
+	for _, player := range options.Players {
+		if game.White == player || game.Black == player {
+			return true
+		}
+	}
+	return false
+}
+
+func (node *OpeningNode) addResult(result WinState) {
+	node.Games++
+	switch result {
+	case WhiteWon:
+		node.WhiteWins++
+	case BlackWon:
+		node.BlackWins++
+	case Stalemate:
+		node.Draws++
+	}
+}
+
+//buildOpeningTree counts the moves of the first options.MaxPly plies of every kept game
+func buildOpeningTree(games []GameRecord, options OpeningBookOptions) *OpeningNode {
+	root := &OpeningNode{Moves: map[string]*OpeningNode{}}
+	for _, game := range games {
+		if !options.keeps(game) {
+			continue
+		}
+		node := root
+		node.addResult(game.Result)
+		for ply, move := range game.Moves {
+			if ply >= options.MaxPly {
+				break
+			}
+			next, ok := node.Moves[move]
+			if !ok {
+				next = &OpeningNode{Moves: map[string]*OpeningNode{}}
+				node.Moves[move] = next
+			}
+			next.addResult(game.Result)
+			node = next
+		}
+	}
+	return root
+}
+
+//prune removes the moves played in fewer than minGames games
+func (node *OpeningNode) prune(minGames int) {
+	for move, next := range node.Moves {
+		if next.Games < minGames {
+			delete(node.Moves, move)
+			continue
+		}
+		next.prune(minGames)
+	}
+}
+
+//score is how well the side that played into this node did, counting a win as 2 and a draw as 1
+func (node *OpeningNode) score(mover Colour) int {
+	if mover == White {
+		return 2*node.WhiteWins + node.Draws
+	}
+	return 2*node.BlackWins + node.Draws
+}
+
+//polyglotEntries turns the tree into book entries weighted by score. Moves reached by transposition are merged
+func (node *OpeningNode) polyglotEntries(keys *PolyglotKeys) []PolyglotEntry {
+	weights := map[[2]uint64]int{}
+	order := [][2]uint64{}
+	var walk func(node *OpeningNode, board Board)
+	walk = func(node *OpeningNode, board Board) {
+		key := keys.key(board)
+		for move, next := range node.Moves {
+			child := board.playMoveName(move)
+			if child == nil {
+				continue
+			}
+			entry := [2]uint64{key, uint64(polyglotMove(child))}
+			if _, ok := weights[entry]; !ok {
+				order = append(order, entry)
+			}
+			weights[entry] += next.score(board.colourToMove)
+			walk(next, *child)
+		}
+	}
+	walk(node, NewBoard())
+
+	largest := 1
+	for _, weight := range weights {
+		if weight > largest {
+			largest = weight
+		}
+	}
+	entries := []PolyglotEntry{}
+	for _, entry := range order {
+		weight := weights[entry]
+		if largest > 0xFFFF {
+			weight = weight * 0xFFFF / largest
+		}
+		if weight > 0 {
+			entries = append(entries, PolyglotEntry{entry[0], uint16(entry[1]), uint16(weight), 0})
+		}
+	}
+	return entries
+}
+
+//readGames reads PGN files and arena game records
+func readGames(paths []string, maxPly int) []GameRecord {
+	games := []GameRecord{}
+	for _, path := range paths {
+		if strings.HasSuffix(path, ".json") {
+			games = append(games, readGameRecords(path, "")...)
+			continue
+		}
+		file, err := ioutil.ReadFile(path)
+		if err != nil {
+			print(err.Error() + "\n")
+			continue
+		}
+		games = append(games, readPGN(string(file), maxPly)...)
+	}
+	return games
+}
+
+//buildOpeningBook builds an opening tree from games and writes it as JSON, or as a Polyglot book when out ends in .bin
+func buildOpeningBook(paths []string, out string, options OpeningBookOptions) error {
+	tree := buildOpeningTree(readGames(paths, options.MaxPly), options)
+	tree.prune(options.MinGames)
+
+	if strings.HasSuffix(out, ".bin") {
+		return writePolyglotBook(tree.polyglotEntries(polyglotKeys), out)
+	}
+
+	fileJson, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(out, fileJson, 0644)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+const testPGN = `[Event "Test"]
+[White "alpha.json"]
+[Black "beta.json"]
+[Result "1-0"]
+
+1. e4 e5 2. Nf3 {the main line} Nc6 (2... d6 3. d4) 3. Bb5 a6 4. Ba4 Nf6 5. O-O Be7 1-0
+
+[White "beta.json"]
+[Black "alpha.json"]
+[Result "0-1"]
+
+1. e4 e5 2. Nf3 Nf6 $1 3. Nxe5 d6 0-1
+
+1. d4 d5 1/2-1/2
+`
+
+func TestPlaySAN(t *testing.T) {
+	positions := []struct {
+		fen  string
+		san  string
+		name string
+	}{
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "O-O-O+", "O-O-O"},
+		{"4k3/8/8/8/8/8/8/R3K2R w KQ - 0 1", "0-0", "O-O"},
+		{"4k3/8/8/8/8/8/8/R3K2R w - - 0 1", "Rad1", "Ra1d1"},
+		{"4k3/8/8/8/8/8/R7/R3K3 w - - 0 1", "R1a1", ""},
+		{"4k3/8/8/8/8/8/R7/R3K3 w - - 0 1", "R2b2", "Ra2b2"},
+		{"4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b8=N", "Pb7b8=N"},
+		{"2n1k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "bxc8Q#", "Pb7c8=Q"},
+		{"4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b8", ""},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "exd6", "Pe5d6"},
+	}
+	for _, position := range positions {
+		board, _ := BoardFromFEN(position.fen)
+		child := board.playSAN(position.san)
+		name := ""
+		if child != nil {
+			name = moveName(child)
+		}
+		if name != position.name {
+			t.Errorf("%s in %s: expected %q but got %q", position.san, position.fen, position.name, name)
+		}
+	}
+}
+
+func TestOpeningTree(t *testing.T) {
+	games := readPGN(testPGN, 4)
+	if len(games) != 3 || games[0].White != "alpha.json" || games[1].Result != BlackWon || games[2].Result != Stalemate {
+		t.Fatalf("read the wrong games: %+v", games)
+	}
+	if len(games[0].Moves) != 4 || games[0].Moves[3] != "Nb8c6" || games[2].Moves[1] != "Pd7d5" {
+		t.Errorf("read the wrong moves: %v %v", games[0].Moves, games[2].Moves)
+	}
+
+	tree := buildOpeningTree(games, OpeningBookOptions{3, 1, nil, nil})
+	e4 := tree.Moves["Pe2e4"]
+	if tree.Games != 3 || e4 == nil || e4.Games != 2 || e4.WhiteWins != 1 || e4.BlackWins != 1 || tree.Moves["Pd2d4"].Draws != 1 {
+		t.Errorf("counted the wrong results: %+v", tree)
+	}
+	if len(e4.Moves["Pe7e5"].Moves["Ng1f3"].Moves) != 0 {
+		t.Errorf("went deeper than 3 plies")
+	}
+
+	tree.prune(2)
+	if len(tree.Moves) != 1 || tree.Moves["Pe2e4"] == nil {
+		t.Errorf("kept moves played in fewer than 2 games: %v", tree.Moves)
+	}
+
+	whiteWins := buildOpeningTree(games, OpeningBookOptions{3, 1, []WinState{WhiteWon}, nil})
+	if whiteWins.Games != 1 || whiteWins.Moves["Pe2e4"].WhiteWins != 1 {
+		t.Errorf("kept games white did not win: %+v", whiteWins)
+	}
+	alphaDraws := buildOpeningTree(games, OpeningBookOptions{3, 1, []WinState{Stalemate}, []string{"alpha.json"}})
+	if alphaDraws.Games != 0 {
+		t.Errorf("kept a draw alpha.json did not play in")
+	}
+}
+
+func TestReadPGNSkipsBrokenGames(t *testing.T) {
+	pgn := `[White "alpha.json"]
+[Result "1-0"]
+
+1. e4 e5 1-0
+
+[White "broken.json"]
+[Result "0-1"]
+
+1. e4 Ke7 2. Qxf7 0-1
+
+[White "gamma.json"]
+[Result "1/2-1/2"]
+
+1. d4 d5 1/2-1/2
+`
+	games := readPGN(pgn, 10)
+	if len(games) != 2 || games[0].White != "alpha.json" || games[1].White != "gamma.json" {
+		t.Fatalf("expected the games around the broken one but got %+v", games)
+	}
+	if len(games[1].Moves) != 2 || games[1].Moves[1] != "Pd7d5" {
+		t.Errorf("read the wrong moves after the broken game: %v", games[1].Moves)
+	}
+}
+
+func TestOpeningTreePolyglotExport(t *testing.T) {
+	keys := testPolyglotKeys()
+	games := []GameRecord{
+		{"a", "b", WhiteWon, []string{"Pe2e4", "Pe7e5"}},
+		{"a", "b", Stalemate, []string{"Pe2e4", "Pc7c5"}},
+		{"a", "b", BlackWon, []string{"Pd2d4", "Pd7d5"}},
+	}
+	tree := buildOpeningTree(games, OpeningBookOptions{2, 1, nil, nil})
+
+	dir, err := ioutil.TempDir("", "book")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := writePolyglotBook(tree.polyglotEntries(keys), dir+"/book.bin"); err != nil {
+		t.Fatal(err)
+	}
+	book, err := readPolyglotBook(dir+"/book.bin", keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//d4 lost its only game, so white always plays e4
+	for i := 0; i < 20; i++ {
+		if move := book.move(NewBoard()); move == nil || moveName(move) != "Pe2e4" {
+			t.Fatalf("expected e4 from the start but got %v", move)
+		}
+	}
+	//black lost with e5 but drew with c5
+	if entries := book.lookup(playPolyglotMoves(t, NewBoard(), "e2e4")); len(entries) != 1 || polyglotMoveString(entries[0].Move) != "c7c5" {
+		t.Errorf("expected only c5 after e4 but got %v", entries)
+	}
+}
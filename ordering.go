@@ -0,0 +1,148 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+//orderingValues are piece values for ordering captures, with the king cheap enough to count as a good attacker
+var orderingValues = map[string]int{"P": 1, "N": 3, "B": 3, "R": 5, "Q": 9, "K": 10}
+
+const (
+	hashMoveScore    = 1 << 30
+	goodCaptureScore = 1 << 28
+	killerScore      = 1 << 27
+	//badCaptureScore puts captures of defended pieces by more valuable ones after the quiet moves
+	badCaptureScore = -(1 << 27)
+)
+
+//MoveOrderer sorts moves so the ones most likely to cause a cutoff are searched first: the hash move, then captures
+//by most valuable victim and least valuable attacker, then the killer moves that caused cutoffs at the same ply,
+//then quiet moves by how often they have caused cutoffs before
+type MoveOrderer struct {
+	killers [maxSearchDepth + 1][2]uint16
+	//history scores quiet moves by colour and from and to square
+	history [2][4096]int
+	//cutoffs counts beta cutoffs, and firstMoveCutoffs the ones caused by the first move searched
+	cutoffs          int
+	firstMoveCutoffs int
+}
+
+func newMoveOrderer() *MoveOrderer {
+	return &MoveOrderer{}
+}
+
+//newSearch forgets the killer moves, which belong to the last position searched, and halves the history so it
+//favours recent cutoffs
+func (orderer *MoveOrderer) newSearch() {
+	orderer.killers = [maxSearchDepth + 1][2]uint16{}
+	for colour := range orderer.history {
+		for move := range orderer.history[colour] {
+			orderer.history[colour][move] /= 2
+		}
+	}
+	orderer.cutoffs = 0
+	orderer.firstMoveCutoffs = 0
+}
+
+//firstMoveCutoffRate is the share of cutoffs caused by the first move searched, which is higher the better moves are
+//ordered
+func (orderer *MoveOrderer) firstMoveCutoffRate() float64 {
+	if orderer.cutoffs == 0 {
+		return 0
+	}
+	return float64(orderer.firstMoveCutoffs) / float64(orderer.cutoffs)
+}
+
+//order sorts the moves from board, keeping the generated order between equal moves. ply is -1 in the quiescence
+//search, which has no killer moves
+func (orderer *MoveOrderer) order(board *Board, moves []*Board, ply int, hashMove uint16) []*Board {
+	scores := map[*Board]int{}
+	for _, move := range moves {
+		scores[move] = orderer.score(board, move, ply, hashMove)
+	}
+	sort.SliceStable(moves, func(i, j int) bool { return scores[moves[i]] > scores[moves[j]] })
+	return moves
+}
+
+func (orderer *MoveOrderer) score(board, child *Board, ply int, hashMove uint16) int {
+	move := polyglotMove(child)
+	if hashMove != 0 && move == hashMove {
+		return hashMoveScore
+	}
+	if isTactical(board, child) {
+		victim, attacker := captureValues(board, child)
+		score := 16*victim - attacker
+		if victim < attacker && isDefended(child) {
+			return badCaptureScore + score
+		}
+		return goodCaptureScore + score
+	}
+	if ply >= 0 && ply <= maxSearchDepth {
+		for i, killer := range orderer.killers[ply] {
+			if killer != 0 && killer == move {
+				return killerScore - i
+			}
+		}
+	}
+	return orderer.history[colourIndex(board.colourToMove)][move&4095]
+}
+
+//cutoff records that child caused a beta cutoff after moveNumber other moves were searched. Quiet moves become
+//killers at this ply and gain history, more so the deeper the search was
+func (orderer *MoveOrderer) cutoff(board, child *Board, ply, depth, moveNumber int) {
+	orderer.cutoffs++
+	if moveNumber == 0 {
+		orderer.firstMoveCutoffs++
+	}
+	if isTactical(board, child) {
+		return
+	}
+	move := polyglotMove(child)
+	if ply >= 0 && ply <= maxSearchDepth && orderer.killers[ply][0] != move {
+		orderer.killers[ply][1] = orderer.killers[ply][0]
+		orderer.killers[ply][0] = move
+	}
+	orderer.history[colourIndex(board.colourToMove)][move&4095] += depth * depth
+}
+
+//captureValues gives the ordering values of the piece child captured and the piece that took it. Promotions count
+//the new piece as part of the victim
+func captureValues(board, child *Board) (int, int) {
+	name := child.lastMoveString
+	if len(name) < 5 || name[0] == 'O' || name[1] == '@' {
+		return 0, 0
+	}
+	attacker := orderingValues[name[:1]]
+	victim := 0
+	if piece := board.squares[name[3]-'a'][name[4]-'1']; piece != nil {
+		victim = orderingValues[piece.pieceType.sign]
+	} else if name[0] == 'P' && name[1] != name[3] {
+		//en passant
+		victim = orderingValues["P"]
+	}
+	if promotion := strings.Index(name, "="); promotion >= 0 && promotion+1 < len(name) {
+		victim += orderingValues[name[promotion+1:promotion+2]] - orderingValues["P"]
+	}
+	return victim, attacker
+}
+
+//isDefended is true when the piece that just moved can be taken back
+func isDefended(child *Board) bool {
+	name := child.lastMoveString
+	if len(name) < 5 || name[0] == 'O' || name[1] == '@' {
+		return false
+	}
+	x, y := name[3]-'a', name[4]-'1'
+	if child.colourToMove == White {
+		return child.coveredSquaresWhite[x][y]
+	}
+	return child.coveredSquaresBlack[x][y]
+}
+
+func colourIndex(colour Colour) int {
+	if colour == Black {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMoveOrder(t *testing.T) {
+	//Qxd5 takes a defended pawn, while the rook on f3 hangs to both the knight and the queen
+	board, _ := BoardFromFEN("4k3/8/4p3/3p4/8/5r2/8/3QK1N1 w - - 0 1")
+	orderer := newMoveOrderer()
+	var hashMove, killer, quiet *Board
+	for _, child := range board.getPossibleMoves() {
+		switch child.lastMoveString {
+		case "Ke1e2 ":
+			hashMove = child
+		case "Qd1a4 ":
+			killer = child
+		case "Qd1b3 ":
+			quiet = child
+		}
+	}
+	orderer.cutoff(&board, killer, 2, 1, 0)
+	orderer.cutoff(&board, quiet, 5, 3, 4)
+
+	moves := orderer.order(&board, board.getPossibleMoves(), 2, polyglotMove(hashMove))
+	expected := []string{"Ke1e2 ", "Ng1f3 ", "Qd1f3 ", "Qd1a4 ", "Qd1b3 "}
+	for i, move := range expected {
+		if moves[i].lastMoveString != move {
+			t.Errorf("expected move %d to be %s but got %s", i, move, moves[i].lastMoveString)
+		}
+	}
+	if last := moves[len(moves)-1].lastMoveString; last != "Qd1d5 " {
+		t.Errorf("expected the queen taking a defended pawn last but got %s", last)
+	}
+
+	if orderer.cutoffs != 2 || orderer.firstMoveCutoffRate() != 0.5 {
+		t.Errorf("expected 1 of 2 cutoffs on the first move but got %d of %d", orderer.firstMoveCutoffs, orderer.cutoffs)
+	}
+	orderer.newSearch()
+	if orderer.killers[2][0] != 0 || orderer.history[0][polyglotMove(quiet)&4095] != 4 || orderer.cutoffs != 0 {
+		t.Errorf("a new search should forget killers and statistics and halve the history")
+	}
+}
+
+func TestPromotionsAreOrderedFirst(t *testing.T) {
+	//the black king defends a8, which should not make promoting a bad capture
+	board, _ := BoardFromFEN("8/Pk6/8/8/8/8/8/4K3 w - - 0 1")
+	moves := newMoveOrderer().order(&board, board.getPossibleMoves(), 0, 0)
+	if moves[0].lastMoveString != "Pa7a8 =Q" {
+		t.Errorf("expected promoting to a queen first but got %s", moves[0].lastMoveString)
+	}
+	for _, move := range moves[:4] {
+		if move.lastMoveString[0] != 'P' {
+			t.Errorf("expected the promotions before %s", move.lastMoveString)
+		}
+	}
+}
+
+func TestMoveOrderingCutsOffEarly(t *testing.T) {
+	board, _ := BoardFromFEN("r1bqkbnr/pppp1ppp/2n5/4p3/3PP3/8/PPP2PPP/RNBQKBNR w KQkq - 0 3")
+	searcher := newAlphaBetaSearcher(verySimpleHeuristic, nil, newTranspositionTable(1))
+	result := searcher.search(context.Background(), &board, 3)
+	if rate := searcher.orderer.firstMoveCutoffRate(); rate < 0.8 {
+		t.Errorf("only %f of cutoffs came from the first move", rate)
+	}
+	if result.firstMoveCutoffRate != searcher.orderer.firstMoveCutoffRate() || !strings.Contains(result.String(), fmt.Sprintf(" firstcutoff %.2f ", result.firstMoveCutoffRate)) {
+		t.Errorf("the search result does not report the first move cutoff rate: %s", result)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import "sync"
+
+//pawnTableSize is how many pawn structures the pawn table remembers. It is a power of two
+const pawnTableSize = 1 << 14
+
+//PawnStructure is what the pawns of a board look like, by colour index, which does not depend on the policy scoring
+//it. passed holds the squares of the passed pawns, which are scored by rank and by how near the kings are
+type PawnStructure struct {
+	doubled   [2]int
+	isolated  [2]int
+	backward  [2]int
+	connected [2]int
+	passed    [2][]Vector
+}
+
+//PawnEntry is a pawn structure with the key of the pawns it belongs to
+type PawnEntry struct {
+	key       uint64
+	structure PawnStructure
+	valid     bool
+}
+
+//PawnTable caches pawn structures by the placement of the pawns, which changes far less often than the rest of the
+//board, so most evaluations find their pawns already worked out
+type PawnTable struct {
+	entries []PawnEntry
+	locks   [transpositionStripes]sync.Mutex
+}
+
+//pawnTable is shared by every search, since pawn structures are the same whichever policy scores them
+var pawnTable = newPawnTable(pawnTableSize)
+
+func newPawnTable(size int) *PawnTable {
+	return &PawnTable{entries: make([]PawnEntry, size)}
+}
+
+//structure gives the pawn structure of board, from the cache when it is there
+func (table *PawnTable) structure(board *Board) PawnStructure {
+	key := searchKeys.pawnKey(*board)
+	index := key & uint64(len(table.entries)-1)
+	lock := &table.locks[index%transpositionStripes]
+	lock.Lock()
+	entry := table.entries[index]
+	lock.Unlock()
+	if entry.valid && entry.key == key {
+		return entry.structure
+	}
+
+	structure := pawnStructure(board)
+	lock.Lock()
+	table.entries[index] = PawnEntry{key, structure, true}
+	lock.Unlock()
+	return structure
+}
+
+//pawnKey hashes only the pawns of a board
+func (keys *SearchKeys) pawnKey(board Board) uint64 {
+	key := uint64(0)
+	for _, piece := range board.pieces {
+		if piece.pieceType.sign != "P" {
+			continue
+		}
+		kind := polyglotPieceKinds["P"]
+		if piece.colour == Black {
+			kind--
+		}
+		key ^= keys.board[64*kind+8*piece.position.Y+piece.position.X]
+	}
+	return key
+}
+
+//pawnStructure finds the doubled, isolated, backward, connected and passed pawns of each side. Isolated pawns have
+//no pawns of their own on the files beside them. Connected pawns have one beside them or one rank behind on a file
+//beside them. Backward pawns have pawns on the files beside them, but all further up the board, and cannot safely
+//advance because an enemy pawn covers the square in front. Passed pawns have no enemy pawns in front of them on their
+//own file or the files beside it
+func pawnStructure(board *Board) PawnStructure {
+	//pawns holds each side's pawns by file and rank, with the ranks from that side's point of view
+	pawns := [2][8][8]bool{}
+	for _, piece := range board.pieces {
+		if piece.pieceType.sign == "P" {
+			pawns[colourIndex(piece.colour)][piece.position.X][relativeRank(piece.position.Y, piece.colour)] = true
+		}
+	}
+
+	structure := PawnStructure{}
+	for side := 0; side < 2; side++ {
+		own, enemy := pawns[side], pawns[1-side]
+		for file := 0; file < 8; file++ {
+			onFile := 0
+			for rank := 0; rank < 8; rank++ {
+				if !own[file][rank] {
+					continue
+				}
+				onFile++
+
+				neighbours, behind, connected := false, true, false
+				for _, beside := range []int{file - 1, file + 1} {
+					if beside < 0 || beside > 7 {
+						continue
+					}
+					for otherRank := 0; otherRank < 8; otherRank++ {
+						if !own[beside][otherRank] {
+							continue
+						}
+						neighbours = true
+						if otherRank <= rank {
+							behind = false
+						}
+						if otherRank == rank || otherRank == rank-1 {
+							connected = true
+						}
+					}
+				}
+
+				if !neighbours {
+					structure.isolated[side]++
+				} else if behind && rank < 6 && coveredByEnemyPawn(enemy, file, rank+1) {
+					structure.backward[side]++
+				}
+				if connected {
+					structure.connected[side]++
+				}
+				if isPassed(enemy, file, rank) {
+					colour := White
+					if side == 1 {
+						colour = Black
+					}
+					structure.passed[side] = append(structure.passed[side], Vector{file, relativeRank(rank, colour)})
+				}
+			}
+			if onFile > 1 {
+				structure.doubled[side] += onFile - 1
+			}
+		}
+	}
+	return structure
+}
+
+//relativeRank gives a rank from colour's side of the board, and turns it back again
+func relativeRank(rank int, colour Colour) int {
+	if colour == Black {
+		return 7 - rank
+	}
+	return rank
+}
+
+//coveredByEnemyPawn is true when an enemy pawn covers a square, given with the rank from the side of the pawn's owner.
+//Enemy pawns are held with ranks from their own side, so the enemy rank is flipped
+func coveredByEnemyPawn(enemy [8][8]bool, file, rank int) bool {
+	enemyRank := 7 - rank - 1
+	if enemyRank < 0 {
+		return false
+	}
+	return file > 0 && enemy[file-1][enemyRank] || file < 7 && enemy[file+1][enemyRank]
+}
+
+//isPassed is true when no enemy pawn stands in front of a pawn on its own file or those beside it
+func isPassed(enemy [8][8]bool, file, rank int) bool {
+	for beside := file - 1; beside <= file+1; beside++ {
+		if beside < 0 || beside > 7 {
+			continue
+		}
+		for enemyRank := 0; enemyRank < 7-rank; enemyRank++ {
+			if enemy[beside][enemyRank] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+//pawnStructureValue scores the pawn structure of a board with the config's terms, from white's point of view. Each
+//passed pawn also scores PassedPawnKingDistance for every square the enemy king is further than its own king from the
+//square in front of it
+func (config *PieceValueConfig) pawnStructureValue(structure PawnStructure, kings map[Colour]Vector) float64 {
+	total := 0.0
+	for side, colour := range []Colour{White, Black} {
+		value := config.DoubledPawn*float64(structure.doubled[side]) + config.IsolatedPawn*float64(structure.isolated[side]) +
+			config.BackwardPawn*float64(structure.backward[side]) + config.ConnectedPawn*float64(structure.connected[side])
+		for _, pawn := range structure.passed[side] {
+			value += config.PassedPawn[relativeRank(pawn.Y, colour)]
+			ownKing, ok := kings[colour]
+			enemyKing, enemyOk := kings[colour.opposite()]
+			if ok && enemyOk && relativeRank(pawn.Y, colour) < 7 {
+				front := Vector{pawn.X, pawn.Y + 1}
+				if colour == Black {
+					front = Vector{pawn.X, pawn.Y - 1}
+				}
+				value += config.PassedPawnKingDistance * float64(kingDistance(enemyKing, front)-kingDistance(ownKing, front))
+			}
+		}
+		if colour == Black {
+			value = -value
+		}
+		total += value
+	}
+	return total
+}
+
+//kingDistance is how many king moves apart two squares are
+func kingDistance(from, to Vector) int {
+	dx, dy := from.X-to.X, from.Y-to.Y
+	if dx < 0 {
+		dx = -dx
+	}
+	if dy < 0 {
+		dy = -dy
+	}
+	if dx > dy {
+		return dx
+	}
+	return dy
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPawnStructure(t *testing.T) {
+	tests := []struct {
+		fen       string
+		structure PawnStructure
+	}{
+		//two pawns on the a file and one on the h file, none with a neighbour and nothing to stop them
+		{"4k3/8/8/8/8/P7/P6P/4K3 w - - 0 1", PawnStructure{[2]int{1, 0}, [2]int{3, 0}, [2]int{}, [2]int{},
+			[2][]Vector{{{0, 1}, {0, 2}, {7, 1}}, nil}}},
+		//d3 cannot advance past the e5 pawn's guard without c4 behind it, while c4 is supported by d3 and passed
+		{"4k3/8/8/4p3/2P5/3P4/8/4K3 w - - 0 1", PawnStructure{[2]int{}, [2]int{0, 1}, [2]int{1, 0}, [2]int{1, 0},
+			[2][]Vector{{{2, 3}}, nil}}},
+		//the same with the colours swapped
+		{"4k3/8/3p4/2p5/4P3/8/8/4K3 w - - 0 1", PawnStructure{[2]int{}, [2]int{1, 0}, [2]int{0, 1}, [2]int{0, 1},
+			[2][]Vector{nil, {{2, 4}}}}},
+	}
+	for _, test := range tests {
+		board := mustFEN(t, test.fen)
+		if structure := pawnStructure(&board); !reflect.DeepEqual(structure, test.structure) {
+			t.Errorf("%s has pawn structure %+v but expected %+v", test.fen, structure, test.structure)
+		}
+		if structure := pawnTable.structure(&board); !reflect.DeepEqual(structure, test.structure) {
+			t.Errorf("%s has pawn structure %+v in the pawn table but expected %+v", test.fen, structure, test.structure)
+		}
+	}
+
+	//only the pawns are hashed
+	withRook, withoutRook, moved := mustFEN(t, "4k3/8/8/8/8/P7/P6P/R3K3 w - - 0 1"), mustFEN(t, "4k3/8/8/8/8/P7/P6P/4K3 w - - 0 1"), mustFEN(t, "4k3/8/8/8/P7/8/P6P/4K3 w - - 0 1")
+	if searchKeys.pawnKey(withRook) != searchKeys.pawnKey(withoutRook) || searchKeys.pawnKey(withoutRook) == searchKeys.pawnKey(moved) {
+		t.Errorf("unexpected pawn keys")
+	}
+}
+
+func TestPassedPawnKingDistance(t *testing.T) {
+	config := PieceValueConfig{PassedPawn: map[int]float64{2: 0.5}, PassedPawnKingDistance: 1}
+	//the black king is 7 moves from a4 and the white king 3
+	white := mustFEN(t, "8/8/8/8/8/P7/8/K6k w - - 0 1")
+	black := mustFEN(t, "k6K/8/p7/8/8/8/8/8 w - - 0 1")
+	if value := generalHeuristic(&white, &config); value != 4.5 {
+		t.Errorf("expected 4.5 for white's passed pawn but got %f", value)
+	}
+	if value := generalHeuristic(&black, &config); value != -4.5 {
+		t.Errorf("expected -4.5 for black's passed pawn but got %f", value)
+	}
+}
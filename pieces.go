@@ -27,26 +27,30 @@ func (piece Piece) canPawnMoveTwo() bool {
 	return false
 }
 
+func (piece Piece) canTakeEnPassant() bool {
+	return (piece.colour == White && piece.position.Y == 4) || (piece.colour == Black && piece.position.Y == 3)
+}
+
 func (piece Piece) getCastlingMoves(boardState Board) []Vector {
 	castlingMoves := []Vector{}
 	if piece.colour == White {
 		if boardState.isWhiteChecked {
 			return []Vector{}
 		}
-		if boardState.canWhiteQueenSideCastle && boardState.getSquare(1, 0) == nil && boardState.getSquare(2, 0) == nil && boardState.getSquare(3, 0) == nil {
-			castlingMoves = append(castlingMoves, Vector{0, 2})
+		if boardState.canWhiteQueenSideCastle && boardState.getSquare(1, 0) == nil && boardState.getSquare(2, 0) == nil && boardState.getSquare(3, 0) == nil && !boardState.coveredSquaresBlack[3][0] {
+			castlingMoves = append(castlingMoves, Vector{2, 0})
 		}
-		if boardState.canWhiteKingSideCastle && boardState.getSquare(5, 0) == nil && boardState.getSquare(6, 0) == nil {
-			castlingMoves = append(castlingMoves, Vector{0, 6})
+		if boardState.canWhiteKingSideCastle && boardState.getSquare(5, 0) == nil && boardState.getSquare(6, 0) == nil && !boardState.coveredSquaresBlack[5][0] {
+			castlingMoves = append(castlingMoves, Vector{6, 0})
 		}
 	} else {
 		if boardState.isBlackChecked {
 			return []Vector{}
 		}
-		if boardState.canBlackQueenSideCastle && boardState.getSquare(1, 7) == nil && boardState.getSquare(2, 7) == nil && boardState.getSquare(3, 7) == nil {
-			castlingMoves = append(castlingMoves, Vector{7, 2})
+		if boardState.canBlackQueenSideCastle && boardState.getSquare(1, 7) == nil && boardState.getSquare(2, 7) == nil && boardState.getSquare(3, 7) == nil && !boardState.coveredSquaresWhite[3][7] {
+			castlingMoves = append(castlingMoves, Vector{2, 7})
 		}
-		if boardState.canBlackKingSideCastle && boardState.getSquare(5, 7) == nil && boardState.getSquare(6, 7) == nil {
+		if boardState.canBlackKingSideCastle && boardState.getSquare(5, 7) == nil && boardState.getSquare(6, 7) == nil && !boardState.coveredSquaresWhite[5][7] {
 			castlingMoves = append(castlingMoves, Vector{6, 7})
 		}
 	}
@@ -97,7 +101,7 @@ func (piece Piece) getPawnMoves(boardState Board) []Vector {
 		colourMult = -1
 	}
 
-	if piece.position.Y+colourMult > 0 && piece.position.Y+colourMult < 8 && boardState.getSquare(piece.position.X, piece.position.Y+colourMult) == nil {
+	if piece.position.Y+colourMult >= 0 && piece.position.Y+colourMult < 8 && boardState.getSquare(piece.position.X, piece.position.Y+colourMult) == nil {
 		foundMoves = append(foundMoves, Vector{piece.position.X, piece.position.Y + colourMult})
 	}
 	if piece.position.X != 7 && piece.position.Y+colourMult >= 0 && piece.position.Y+colourMult < 8 {
@@ -105,7 +109,7 @@ func (piece Piece) getPawnMoves(boardState Board) []Vector {
 			foundMoves = append(foundMoves, Vector{piece.position.X + 1, piece.position.Y + colourMult})
 		}
 
-		if occupyingPiece := boardState.getSquare(piece.position.X+1, piece.position.Y); occupyingPiece != nil && occupyingPiece.pieceType.sign == "P" && occupyingPiece.position.X == boardState.enPassantRank {
+		if occupyingPiece := boardState.getSquare(piece.position.X+1, piece.position.Y); occupyingPiece != nil && occupyingPiece.pieceType.sign == "P" && occupyingPiece.colour != piece.colour && piece.canTakeEnPassant() && occupyingPiece.position.X == boardState.enPassantRank {
 			foundMoves = append(foundMoves, Vector{piece.position.X + 1, piece.position.Y + colourMult})
 		}
 	}
@@ -114,7 +118,7 @@ func (piece Piece) getPawnMoves(boardState Board) []Vector {
 			foundMoves = append(foundMoves, Vector{piece.position.X - 1, piece.position.Y + colourMult})
 		}
 
-		if occupyingPiece := boardState.getSquare(piece.position.X-1, piece.position.Y); occupyingPiece != nil && occupyingPiece.pieceType.sign == "P" && occupyingPiece.position.X == boardState.enPassantRank {
+		if occupyingPiece := boardState.getSquare(piece.position.X-1, piece.position.Y); occupyingPiece != nil && occupyingPiece.pieceType.sign == "P" && occupyingPiece.colour != piece.colour && piece.canTakeEnPassant() && occupyingPiece.position.X == boardState.enPassantRank {
 			foundMoves = append(foundMoves, Vector{piece.position.X - 1, piece.position.Y + colourMult})
 		}
 	}
@@ -211,7 +215,11 @@ func (piece Piece) hasPossibleMoves(boardState Board) bool {
 	if piece.pieceType.sign == "P" {
 		pawnMoves := piece.getPawnMoves(boardState)
 		for _, m := range pawnMoves {
-			nextState := boardState.MakeMove(&piece, m, nil)
+			var promotion *PieceType
+			if m.Y == 0 || m.Y == 7 {
+				promotion = &queen
+			}
+			nextState := boardState.MakeMove(&piece, m, promotion)
 			if nextState.verifyBoardState() {
 				return true
 			}
@@ -404,3 +412,12 @@ var bishop = PieceType{"B", []Vector{{-1, 1}, {1, 1}}, []Vector{}}
 var queen = PieceType{"Q", []Vector{{0, 1}, {1, 0}, {-1, 1}, {1, 1}}, []Vector{}}
 
 var king = PieceType{"K", []Vector{}, []Vector{{1, -1}, {1, 0}, {1, 1}, {0, -1}, {0, 1}, {-1, -1}, {-1, 0}, {-1, 1}}}
+
+var pieceTypes = map[string]*PieceType{
+	"P": &pawn,
+	"R": &rook,
+	"N": &knight,
+	"B": &bishop,
+	"Q": &queen,
+	"K": &king,
+}
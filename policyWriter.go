@@ -3,7 +3,9 @@ package main
 import (
 	"encoding/json"
 	"io/ioutil"
+	"math"
 	"math/rand"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -32,8 +34,38 @@ type PieceValueConfig struct {
 	// skewerMultExp                  map[string]map[string]float64
 	// skewerAdder                    map[string]map[string]map[string]float64
 	// skewerAdderCoeff               map[string]map[string]float64
-	SquareBaseValues map[Vector]float64 `json:"squareBaseValues"`
-	CoveredByMod     map[string]float64 `json:"coveredByMod"`
+	SquareBaseValues map[Vector]float64         `json:"squareBaseValues"`
+	CoveredByMod     map[string]float64         `json:"coveredByMod"`
+	PocketMod        map[string]map[int]float64 `json:"pocketMod"`
+	//SideRelative tables are read from the side of the piece's owner, so Black's squares are mirrored. Otherwise squares are absolute
+	SideRelative bool `json:"sideRelative"`
+	//BlackPositionMod and BlackSquareBaseValues replace PositionMod and SquareBaseValues for black pieces when set
+	BlackPositionMod      map[string]map[Vector]float64 `json:"blackPositionMod"`
+	BlackSquareBaseValues map[Vector]float64            `json:"blackSquareBaseValues"`
+	//DoubledPawn, IsolatedPawn, BackwardPawn and ConnectedPawn are added for each pawn of that kind. PassedPawn is
+	//added for each passed pawn by its rank from its own side, and PassedPawnKingDistance for every square the enemy
+	//king is further than its own from the square in front of it
+	DoubledPawn            float64         `json:"doubledPawn"`
+	IsolatedPawn           float64         `json:"isolatedPawn"`
+	BackwardPawn           float64         `json:"backwardPawn"`
+	ConnectedPawn          float64         `json:"connectedPawn"`
+	PassedPawn             map[int]float64 `json:"passedPawn"`
+	PassedPawnKingDistance float64         `json:"passedPawnKingDistance"`
+	//PawnShield and PawnStorm are added for the nearest own and enemy pawn in front of a king on its file and those
+	//beside it, by how many ranks in front of the king it stands. OpenFileNearKing and SemiOpenFileNearKing are added
+	//for those files with no pawns, or only enemy pawns. KingZoneCovered is added for each square next to the king the
+	//enemy covers, and KingZoneAttackMod for each one covered by an enemy piece of that type, with their total
+	//multiplied by KingAttackerCountMod for the number of enemy pieces attacking
+	PawnShield           map[int]float64    `json:"pawnShield"`
+	PawnStorm            map[int]float64    `json:"pawnStorm"`
+	OpenFileNearKing     float64            `json:"openFileNearKing"`
+	SemiOpenFileNearKing float64            `json:"semiOpenFileNearKing"`
+	KingZoneCovered      float64            `json:"kingZoneCovered"`
+	KingZoneAttackMod    map[string]float64 `json:"kingZoneAttackMod"`
+	KingAttackerCountMod map[int]float64    `json:"kingAttackerCountMod"`
+	//Endgame holds the endgame variant of every term, which the terms above are blended into as material comes off.
+	//Configs without one use the same terms throughout the game
+	Endgame *PieceValueConfig `json:"endgame"`
 	// pieceDupleFormation            pieceDupleFormation
 	// pieceFormationChainer          map[*pieceDupleFormation]map[*pieceDupleFormation]float64
 }
@@ -47,6 +79,24 @@ type PieceValueConfigJsonified struct {
 	RemainingOpponentPiecesTypeMod map[string]map[string]float64 `json:"remainingOpponentPiecesTypeMod"`
 	SquareBaseValues               map[string]float64            `json:"squareBaseValues"`
 	CoveredByMod                   map[string]float64            `json:"coveredByMod"`
+	PocketMod                      map[string]map[int]float64    `json:"pocketMod"`
+	SideRelative                   bool                          `json:"sideRelative"`
+	BlackPositionMod               map[string]map[string]float64 `json:"blackPositionMod,omitempty"`
+	BlackSquareBaseValues          map[string]float64            `json:"blackSquareBaseValues,omitempty"`
+	DoubledPawn                    float64                       `json:"doubledPawn,omitempty"`
+	IsolatedPawn                   float64                       `json:"isolatedPawn,omitempty"`
+	BackwardPawn                   float64                       `json:"backwardPawn,omitempty"`
+	ConnectedPawn                  float64                       `json:"connectedPawn,omitempty"`
+	PassedPawn                     map[int]float64               `json:"passedPawn,omitempty"`
+	PassedPawnKingDistance         float64                       `json:"passedPawnKingDistance,omitempty"`
+	PawnShield                     map[int]float64               `json:"pawnShield,omitempty"`
+	PawnStorm                      map[int]float64               `json:"pawnStorm,omitempty"`
+	OpenFileNearKing               float64                       `json:"openFileNearKing,omitempty"`
+	SemiOpenFileNearKing           float64                       `json:"semiOpenFileNearKing,omitempty"`
+	KingZoneCovered                float64                       `json:"kingZoneCovered,omitempty"`
+	KingZoneAttackMod              map[string]float64            `json:"kingZoneAttackMod,omitempty"`
+	KingAttackerCountMod           map[int]float64               `json:"kingAttackerCountMod,omitempty"`
+	Endgame                        *PieceValueConfigJsonified    `json:"endgame,omitempty"`
 }
 
 // func generateRandomGrid(sd float64, mean float64) [8][8]float64 {
@@ -60,61 +110,175 @@ type PieceValueConfigJsonified struct {
 // }
 
 func (pieceValueConfig PieceValueConfig) marshalJson() PieceValueConfigJsonified {
-	output := PieceValueConfigJsonified{
+	var endgame *PieceValueConfigJsonified
+	if pieceValueConfig.Endgame != nil {
+		marshalled := pieceValueConfig.Endgame.marshalJson()
+		endgame = &marshalled
+	}
+	return PieceValueConfigJsonified{
 		BaseValues:                     pieceValueConfig.BaseValues,
-		PositionMod:                    map[string]map[string]float64{},
+		PositionMod:                    marshalPositionMod(pieceValueConfig.PositionMod),
 		RemainingAlliedPiecesMod:       pieceValueConfig.RemainingAlliedPiecesMod,
 		RemainingOpponentPiecesMod:     pieceValueConfig.RemainingOpponentPiecesMod,
 		RemainingAlliedPiecesTypeMod:   pieceValueConfig.RemainingAlliedPiecesTypeMod,
 		RemainingOpponentPiecesTypeMod: pieceValueConfig.RemainingOpponentPiecesTypeMod,
-		SquareBaseValues:               map[string]float64{},
+		SquareBaseValues:               marshalVectorMap(pieceValueConfig.SquareBaseValues),
 		CoveredByMod:                   pieceValueConfig.CoveredByMod,
+		PocketMod:                      pieceValueConfig.PocketMod,
+		SideRelative:                   pieceValueConfig.SideRelative,
+		BlackPositionMod:               marshalPositionMod(pieceValueConfig.BlackPositionMod),
+		BlackSquareBaseValues:          marshalVectorMap(pieceValueConfig.BlackSquareBaseValues),
+		DoubledPawn:                    pieceValueConfig.DoubledPawn,
+		IsolatedPawn:                   pieceValueConfig.IsolatedPawn,
+		BackwardPawn:                   pieceValueConfig.BackwardPawn,
+		ConnectedPawn:                  pieceValueConfig.ConnectedPawn,
+		PassedPawn:                     pieceValueConfig.PassedPawn,
+		PassedPawnKingDistance:         pieceValueConfig.PassedPawnKingDistance,
+		PawnShield:                     pieceValueConfig.PawnShield,
+		PawnStorm:                      pieceValueConfig.PawnStorm,
+		OpenFileNearKing:               pieceValueConfig.OpenFileNearKing,
+		SemiOpenFileNearKing:           pieceValueConfig.SemiOpenFileNearKing,
+		KingZoneCovered:                pieceValueConfig.KingZoneCovered,
+		KingZoneAttackMod:              pieceValueConfig.KingZoneAttackMod,
+		KingAttackerCountMod:           pieceValueConfig.KingAttackerCountMod,
+		Endgame:                        endgame,
 	}
-
-	for key, vectMap := range pieceValueConfig.PositionMod {
-		modifiedMap := map[string]float64{}
-		for vectKey, val := range vectMap {
-			modifiedMap[vectKey.toString()] = val
-		}
-
-		output.PositionMod[key] = modifiedMap
-	}
-
-	for key, val := range pieceValueConfig.SquareBaseValues {
-		output.SquareBaseValues[key.toString()] = val
-	}
-
-	return output
 }
 
 func (pieceValueConfig PieceValueConfigJsonified) unmarshalJson() PieceValueConfig {
-	output := PieceValueConfig{
+	var endgame *PieceValueConfig
+	if pieceValueConfig.Endgame != nil {
+		unmarshalled := pieceValueConfig.Endgame.unmarshalJson()
+		endgame = &unmarshalled
+	}
+	return PieceValueConfig{
 		BaseValues:                     pieceValueConfig.BaseValues,
-		PositionMod:                    map[string]map[Vector]float64{},
+		PositionMod:                    unmarshalPositionMod(pieceValueConfig.PositionMod),
 		RemainingAlliedPiecesMod:       pieceValueConfig.RemainingAlliedPiecesMod,
 		RemainingOpponentPiecesMod:     pieceValueConfig.RemainingOpponentPiecesMod,
 		RemainingAlliedPiecesTypeMod:   pieceValueConfig.RemainingAlliedPiecesTypeMod,
 		RemainingOpponentPiecesTypeMod: pieceValueConfig.RemainingOpponentPiecesTypeMod,
-		SquareBaseValues:               map[Vector]float64{},
+		SquareBaseValues:               unmarshalVectorMap(pieceValueConfig.SquareBaseValues),
 		CoveredByMod:                   pieceValueConfig.CoveredByMod,
+		PocketMod:                      pieceValueConfig.PocketMod,
+		SideRelative:                   pieceValueConfig.SideRelative,
+		BlackPositionMod:               unmarshalPositionMod(pieceValueConfig.BlackPositionMod),
+		BlackSquareBaseValues:          unmarshalVectorMap(pieceValueConfig.BlackSquareBaseValues),
+		DoubledPawn:                    pieceValueConfig.DoubledPawn,
+		IsolatedPawn:                   pieceValueConfig.IsolatedPawn,
+		BackwardPawn:                   pieceValueConfig.BackwardPawn,
+		ConnectedPawn:                  pieceValueConfig.ConnectedPawn,
+		PassedPawn:                     pieceValueConfig.PassedPawn,
+		PassedPawnKingDistance:         pieceValueConfig.PassedPawnKingDistance,
+		PawnShield:                     pieceValueConfig.PawnShield,
+		PawnStorm:                      pieceValueConfig.PawnStorm,
+		OpenFileNearKing:               pieceValueConfig.OpenFileNearKing,
+		SemiOpenFileNearKing:           pieceValueConfig.SemiOpenFileNearKing,
+		KingZoneCovered:                pieceValueConfig.KingZoneCovered,
+		KingZoneAttackMod:              pieceValueConfig.KingZoneAttackMod,
+		KingAttackerCountMod:           pieceValueConfig.KingAttackerCountMod,
+		Endgame:                        endgame,
 	}
+}
 
-	for key, vectMap := range pieceValueConfig.PositionMod {
-		modifiedMap := map[Vector]float64{}
-		for vectKey, val := range vectMap {
-			modifiedMap[fromString(vectKey)] = val
-		}
+func marshalVectorMap(vectMap map[Vector]float64) map[string]float64 {
+	if vectMap == nil {
+		return nil
+	}
+	modifiedMap := map[string]float64{}
+	for vectKey, val := range vectMap {
+		modifiedMap[vectKey.toString()] = val
+	}
+	return modifiedMap
+}
 
-		output.PositionMod[key] = modifiedMap
+func unmarshalVectorMap(vectMap map[string]float64) map[Vector]float64 {
+	if vectMap == nil {
+		return nil
 	}
+	modifiedMap := map[Vector]float64{}
+	for vectKey, val := range vectMap {
+		modifiedMap[fromString(vectKey)] = val
+	}
+	return modifiedMap
+}
 
-	for key, val := range pieceValueConfig.SquareBaseValues {
-		output.SquareBaseValues[fromString(key)] = val
+func marshalPositionMod(positionMod map[string]map[Vector]float64) map[string]map[string]float64 {
+	if positionMod == nil {
+		return nil
+	}
+	output := map[string]map[string]float64{}
+	for key, vectMap := range positionMod {
+		output[key] = marshalVectorMap(vectMap)
 	}
+	return output
+}
 
+func unmarshalPositionMod(positionMod map[string]map[string]float64) map[string]map[Vector]float64 {
+	if positionMod == nil {
+		return nil
+	}
+	output := map[string]map[Vector]float64{}
+	for key, vectMap := range positionMod {
+		output[key] = unmarshalVectorMap(vectMap)
+	}
 	return output
 }
 
+//relativeSquare gives the square as seen from the side of colour, when the config's tables are side relative
+func (pieceValueConfig *PieceValueConfig) relativeSquare(square Vector, colour Colour) Vector {
+	if pieceValueConfig.SideRelative && colour == Black {
+		return square.mirror()
+	}
+	return square
+}
+
+func (pieceValueConfig *PieceValueConfig) positionMod(piece *Piece) float64 {
+	positionMod := pieceValueConfig.PositionMod
+	if piece.colour == Black && pieceValueConfig.BlackPositionMod != nil {
+		positionMod = pieceValueConfig.BlackPositionMod
+	}
+	return positionMod[piece.pieceType.sign][pieceValueConfig.relativeSquare(piece.position, piece.colour)]
+}
+
+func (pieceValueConfig *PieceValueConfig) squareBaseValue(square Vector, colour Colour) float64 {
+	squareBaseValues := pieceValueConfig.SquareBaseValues
+	if colour == Black && pieceValueConfig.BlackSquareBaseValues != nil {
+		squareBaseValues = pieceValueConfig.BlackSquareBaseValues
+	}
+	return squareBaseValues[pieceValueConfig.relativeSquare(square, colour)]
+}
+
+//toSideRelative converts a config with absolute square tables into side relative form without changing its
+//evaluations. The absolute tables applied to both colours, so Black gets its own mirrored copy of them
+func (pieceValueConfig PieceValueConfig) toSideRelative() PieceValueConfig {
+	if pieceValueConfig.Endgame != nil {
+		endgame := pieceValueConfig.Endgame.toSideRelative()
+		pieceValueConfig.Endgame = &endgame
+	}
+	if pieceValueConfig.SideRelative {
+		return pieceValueConfig
+	}
+
+	mirrorVectorMap := func(vectMap map[Vector]float64) map[Vector]float64 {
+		mirroredMap := map[Vector]float64{}
+		for square, val := range vectMap {
+			mirroredMap[square.mirror()] = val
+		}
+		return mirroredMap
+	}
+
+	blackPositionMod := map[string]map[Vector]float64{}
+	for key, vectMap := range pieceValueConfig.PositionMod {
+		blackPositionMod[key] = mirrorVectorMap(vectMap)
+	}
+
+	pieceValueConfig.SideRelative = true
+	pieceValueConfig.BlackPositionMod = blackPositionMod
+	pieceValueConfig.BlackSquareBaseValues = mirrorVectorMap(pieceValueConfig.SquareBaseValues)
+	return pieceValueConfig
+}
+
 func generateRandomVectorMap(sd float64, mean float64) map[Vector]float64 {
 	vectorMap := map[Vector]float64{}
 	for i := 0; i < 8; i++ {
@@ -147,6 +311,61 @@ func generateRandomPieceMap(sd float64, mean float64) map[string]float64 {
 func randomConfigGenerator(name string, dir string) {
 	rand.Seed(time.Now().UnixNano())
 
+	config := randomConfig()
+
+	writeConfig(Policy{HeauristicConfig: config.marshalJson()}, name, dir)
+}
+
+//randomConfig makes a policy with random middlegame and endgame terms
+func randomConfig() PieceValueConfig {
+	config := randomPhaseConfig(false)
+	endgame := randomPhaseConfig(true)
+	config.Endgame = &endgame
+	return config
+}
+
+//randomKingPositionMod makes a king table that, on average, keeps the king at home behind its pawns in the
+//middlegame and brings it to the centre in the endgame. Tables are side relative, so rank 0 is the king's own
+func randomKingPositionMod(endgame bool) map[Vector]float64 {
+	vectorMap := map[Vector]float64{}
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			mean := 1.1 - 0.05*float64(j)
+			if endgame {
+				mean = 1.1 - 0.05*math.Max(math.Abs(float64(i)-3.5), math.Abs(float64(j)-3.5))
+			}
+			vectorMap[Vector{X: i, Y: j}] = rand.NormFloat64()*0.05 + mean
+		}
+	}
+	return vectorMap
+}
+
+//randomPassedPawnMod draws bonuses for passed pawns on ranks 1 to 6 from their own side, larger the further they have
+//got. Pawns on rank 7 would already have promoted
+func randomPassedPawnMod() map[int]float64 {
+	intMap := map[int]float64{}
+	for rank := 1; rank < 7; rank++ {
+		intMap[rank] = rand.NormFloat64()*0.3 + 0.5*float64(rank)
+	}
+	return intMap
+}
+
+//randomKingAttackerCountMod draws multipliers for the king zone attacks of 0 to 15 enemy pieces. A lone attacker
+//counts for little, with each one after it adding half as much as the one before
+func randomKingAttackerCountMod() map[int]float64 {
+	intMap := map[int]float64{}
+	for count := 0; count < 16; count++ {
+		mean := 0.0
+		if count > 0 {
+			mean = 1 - math.Pow(0.5, float64(count-1))
+		}
+		intMap[count] = rand.NormFloat64()*0.1 + mean
+	}
+	return intMap
+}
+
+//randomPhaseConfig makes the random terms of one phase of a policy
+func randomPhaseConfig(endgame bool) PieceValueConfig {
 	baseValues := generateRandomPieceMap(5, 5)
 
 	positionMod := map[string]map[Vector]float64{
@@ -155,7 +374,7 @@ func randomConfigGenerator(name string, dir string) {
 		"N": generateRandomVectorMap(0.1, 1),
 		"B": generateRandomVectorMap(0.1, 1),
 		"Q": generateRandomVectorMap(0.1, 1),
-		"K": generateRandomVectorMap(0.1, 1),
+		"K": randomKingPositionMod(endgame),
 	}
 
 	remainingAlliedPiecesMod := map[string]map[int]float64{
@@ -213,6 +432,35 @@ func randomConfigGenerator(name string, dir string) {
 
 	coveredByMod := generateRandomPieceMap(0.1, 1)
 
+	pocketMod := map[string]map[int]float64{
+		"P": generateRandomIntMap(17, 0.1, 1),
+		"R": generateRandomIntMap(5, 0.1, 1),
+		"N": generateRandomIntMap(5, 0.1, 1),
+		"B": generateRandomIntMap(5, 0.1, 1),
+		"Q": generateRandomIntMap(10, 0.1, 1),
+	}
+
+	//kings only start shepherding passed pawns once the board has emptied
+	passedPawnKingDistance := rand.NormFloat64() * 0.1
+	if endgame {
+		passedPawnKingDistance = rand.NormFloat64()*0.2 + 0.5
+	}
+
+	//a king with most of the pieces off has little to fear and much to do
+	kingSafetyScale := 1.0
+	if endgame {
+		kingSafetyScale = 0.2
+	}
+	pawnShield := map[int]float64{
+		1: (rand.NormFloat64()*0.2 + 0.5) * kingSafetyScale,
+		2: (rand.NormFloat64()*0.2 + 0.25) * kingSafetyScale,
+	}
+	pawnStorm := map[int]float64{}
+	for ahead := 1; ahead < 7; ahead++ {
+		pawnStorm[ahead] = (rand.NormFloat64()*0.1 - 0.5/float64(ahead)) * kingSafetyScale
+	}
+	kingZoneAttackMod := generateRandomPieceMap(0.05, -0.1*kingSafetyScale)
+
 	config := PieceValueConfig{
 		BaseValues:                     baseValues,
 		PositionMod:                    positionMod,
@@ -236,13 +484,39 @@ func randomConfigGenerator(name string, dir string) {
 		// skewerAdderCoeff: skewerAdderCoeff
 		SquareBaseValues: squareBaseValues,
 		CoveredByMod:     coveredByMod,
+		PocketMod:        pocketMod,
+		SideRelative:     true,
+
+		DoubledPawn:            rand.NormFloat64()*0.5 - 1,
+		IsolatedPawn:           rand.NormFloat64()*0.5 - 1,
+		BackwardPawn:           rand.NormFloat64()*0.3 - 0.5,
+		ConnectedPawn:          rand.NormFloat64()*0.3 + 0.5,
+		PassedPawn:             randomPassedPawnMod(),
+		PassedPawnKingDistance: passedPawnKingDistance,
+
+		PawnShield:           pawnShield,
+		PawnStorm:            pawnStorm,
+		OpenFileNearKing:     (rand.NormFloat64()*0.2 - 0.5) * kingSafetyScale,
+		SemiOpenFileNearKing: (rand.NormFloat64()*0.2 - 0.3) * kingSafetyScale,
+		KingZoneCovered:      (rand.NormFloat64()*0.05 - 0.1) * kingSafetyScale,
+		KingZoneAttackMod:    kingZoneAttackMod,
+		KingAttackerCountMod: randomKingAttackerCountMod(),
 	}
 
-	writeConfig(Policy{HeauristicConfig: config.marshalJson()}, name, dir)
+	return config
 }
 
 type Policy struct {
 	HeauristicConfig PieceValueConfigJsonified `json:"heauristicConfig"`
+	Player           PlayerConfig              `json:"player"`
+}
+
+//PlayerConfig is how a policy plays in the arena: the searcher and evaluator it uses by name, and limits that replace
+//the arena's when set. Policies without one search with alpha-beta and their own heuristic
+type PlayerConfig struct {
+	Searcher  string        `json:"searcher,omitempty"`
+	Evaluator string        `json:"evaluator,omitempty"`
+	Limits    *SearchLimits `json:"limits,omitempty"`
 }
 
 func writeConfig(data Policy, fileName string, dir string) {
@@ -273,3 +547,21 @@ func writeRandomConfigs(dir string, number int) {
 		randomConfigGenerator(uuid.NewString(), dir)
 	}
 }
+
+//migratePolicies rewrites every policy in dir that still uses absolute square tables into side relative form
+func migratePolicies(dir string) {
+	files, _ := ioutil.ReadDir(dir)
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		policy := readConfigJson(file.Name(), dir)
+		if policy.HeauristicConfig.SideRelative {
+			continue
+		}
+		config := policy.HeauristicConfig.unmarshalJson().toSideRelative()
+		policy.HeauristicConfig = config.marshalJson()
+		writeConfig(policy, strings.TrimSuffix(file.Name(), ".json"), dir)
+		print("migrated " + file.Name() + "\n")
+	}
+}
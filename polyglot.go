@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+const (
+	polyglotCastleOffset    = 768
+	polyglotEnPassantOffset = 772
+	polyglotTurnOffset      = 780
+)
+
+//PolyglotKeys are the 781 random numbers Polyglot hashes positions with: 768 for the pieces, 4 for castling rights,
+//8 for en passant files and 1 for white to move
+type PolyglotKeys [781]uint64
+
+//polyglotPieceKinds give the offset of each piece type, with black pieces one lower than white ones
+var polyglotPieceKinds = map[string]int{"P": 1, "N": 3, "B": 5, "R": 7, "Q": 9, "K": 11}
+
+//polyglotPromotions are the promotion pieces in the order Polyglot numbers them from 1
+var polyglotPromotions = []string{"N", "B", "R", "Q"}
+
+//key hashes a board the way Polyglot does. The en passant file only counts when a pawn can take en passant
+func (keys *PolyglotKeys) key(board Board) uint64 {
+	key := uint64(0)
+	for _, piece := range board.pieces {
+		kind := polyglotPieceKinds[piece.pieceType.sign]
+		if piece.colour == Black {
+			kind--
+		}
+		key ^= keys[64*kind+8*piece.position.Y+piece.position.X]
+	}
+
+	castlingRights := []bool{board.canWhiteKingSideCastle, board.canWhiteQueenSideCastle, board.canBlackKingSideCastle, board.canBlackQueenSideCastle}
+	for i, canCastle := range castlingRights {
+		if canCastle {
+			key ^= keys[polyglotCastleOffset+i]
+		}
+	}
+
+	if board.enPassantRank >= 0 {
+		rank := 4
+		if board.colourToMove == Black {
+			rank = 3
+		}
+		for _, x := range []int{board.enPassantRank - 1, board.enPassantRank + 1} {
+			if x < 0 || x > 7 {
+				continue
+			}
+			if piece := board.squares[x][rank]; piece != nil && piece.pieceType.sign == "P" && piece.colour == board.colourToMove {
+				key ^= keys[polyglotEnPassantOffset+board.enPassantRank]
+				break
+			}
+		}
+	}
+
+	if board.colourToMove == White {
+		key ^= keys[polyglotTurnOffset]
+	}
+	return key
+}
+
+//PolyglotEntry is one move of a Polyglot book
+type PolyglotEntry struct {
+	Key    uint64
+	Move   uint16
+	Weight uint16
+	Learn  uint32
+}
+
+//PolyglotBook is a Polyglot opening book with the random numbers to look positions up with
+type PolyglotBook struct {
+	keys    *PolyglotKeys
+	entries []PolyglotEntry
+}
+
+//readPolyglotBook reads a .bin book, whose entries are 16 big-endian bytes sorted by key
+func readPolyglotBook(path string, keys *PolyglotKeys) (*PolyglotBook, error) {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(file)%16 != 0 {
+		return nil, fmt.Errorf("%s is not a whole number of book entries", path)
+	}
+
+	book := &PolyglotBook{keys, []PolyglotEntry{}}
+	for i := 0; i < len(file); i += 16 {
+		book.entries = append(book.entries, PolyglotEntry{
+			Key:    binary.BigEndian.Uint64(file[i:]),
+			Move:   binary.BigEndian.Uint16(file[i+8:]),
+			Weight: binary.BigEndian.Uint16(file[i+10:]),
+			Learn:  binary.BigEndian.Uint32(file[i+12:]),
+		})
+	}
+	sort.SliceStable(book.entries, func(i, j int) bool { return book.entries[i].Key < book.entries[j].Key })
+	return book, nil
+}
+
+//loadPolyglotBook reads a book hashed with the standard Polyglot keys, printing why when it cannot be used
+func loadPolyglotBook(bookPath string) *PolyglotBook {
+	book, err := readPolyglotBook(bookPath, polyglotKeys)
+	if err != nil {
+		print(err.Error() + "\n")
+		return nil
+	}
+	return book
+}
+
+func writePolyglotBook(entries []PolyglotEntry, path string) error {
+	sorted := append([]PolyglotEntry{}, entries...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+
+	out := make([]byte, 16*len(sorted))
+	for i, entry := range sorted {
+		binary.BigEndian.PutUint64(out[16*i:], entry.Key)
+		binary.BigEndian.PutUint16(out[16*i+8:], entry.Move)
+		binary.BigEndian.PutUint16(out[16*i+10:], entry.Weight)
+		binary.BigEndian.PutUint32(out[16*i+12:], entry.Learn)
+	}
+	return ioutil.WriteFile(path, out, 0644)
+}
+
+//lookup returns the book's entries for a board
+func (book *PolyglotBook) lookup(board Board) []PolyglotEntry {
+	key := book.keys.key(board)
+	start := sort.Search(len(book.entries), func(i int) bool { return book.entries[i].Key >= key })
+	end := start
+	for end < len(book.entries) && book.entries[end].Key == key {
+		end++
+	}
+	return book.entries[start:end]
+}
+
+//polyglotMove encodes the move that led to child in Polyglot's format. Castling is written as the king taking its
+//own rook
+func polyglotMove(child *Board) uint16 {
+	move := child.lastMoveString
+	homeRank := 0
+	if child.colourToMove == White {
+		homeRank = 7
+	}
+
+	var from, to Vector
+	promotion := 0
+	switch move {
+	case "O-O":
+		from, to = Vector{4, homeRank}, Vector{7, homeRank}
+	case "O-O-O":
+		from, to = Vector{4, homeRank}, Vector{0, homeRank}
+	default:
+		if len(move) < 5 {
+			return 0
+		}
+		from = Vector{int(move[1] - 'a'), int(move[2] - '1')}
+		to = Vector{int(move[3] - 'a'), int(move[4] - '1')}
+		if split := strings.Index(move, "="); split >= 0 {
+			for i, sign := range polyglotPromotions {
+				if move[split+1:] == sign {
+					promotion = i + 1
+				}
+			}
+		}
+	}
+	return uint16(to.X | to.Y<<3 | from.X<<6 | from.Y<<9 | promotion<<12)
+}
+
+//polyglotMoveString writes a Polyglot move as from and to squares, such as "e2e4" or "e7e8q"
+func polyglotMoveString(move uint16) string {
+	from := Vector{int(move>>6) & 7, int(move>>9) & 7}
+	to := Vector{int(move) & 7, int(move>>3) & 7}
+	out := from.boardPosition() + to.boardPosition()
+	if promotion := int(move>>12) & 7; promotion > 0 && promotion <= len(polyglotPromotions) {
+		out += strings.ToLower(polyglotPromotions[promotion-1])
+	}
+	return out
+}
+
+//move picks one of the book's moves for a board at random, weighted by entry weight. It returns nil when there is no
+//book or the board is not in it
+func (book *PolyglotBook) move(board Board) *Board {
+	if book == nil {
+		return nil
+	}
+	entries := book.lookup(board)
+	if len(entries) == 0 {
+		return nil
+	}
+
+	children := map[uint16]*Board{}
+	for _, child := range board.getPossibleMoves() {
+		children[polyglotMove(child)] = child
+	}
+
+	candidates := []*Board{}
+	weights := []int{}
+	total := 0
+	for _, entry := range entries {
+		if child, ok := children[entry.Move]; ok && entry.Weight > 0 {
+			candidates = append(candidates, child)
+			weights = append(weights, int(entry.Weight))
+			total += int(entry.Weight)
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+
+	pick := rand.Intn(total)
+	for i, weight := range weights {
+		if pick < weight {
+			return candidates[i]
+		}
+		pick -= weight
+	}
+	return nil
+}
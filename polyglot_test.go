@@ -0,0 +1,134 @@
+package main
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func testPolyglotKeys() *PolyglotKeys {
+	random := rand.New(rand.NewSource(1))
+	keys := &PolyglotKeys{}
+	for i := range keys {
+		keys[i] = random.Uint64()
+	}
+	return keys
+}
+
+//playPolyglotMoves plays moves written as from and to squares, such as "e2e4" or "e1h1" for castling
+func playPolyglotMoves(t *testing.T, board Board, moves ...string) Board {
+	for _, move := range moves {
+		found := false
+		for _, child := range board.getPossibleMoves() {
+			if polyglotMoveString(polyglotMove(child)) == move {
+				board = *child
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("%s is not a legal move in %s", move, board.ToFEN())
+		}
+	}
+	return board
+}
+
+func TestPolyglotKeyTranspositions(t *testing.T) {
+	keys := testPolyglotKeys()
+	start := NewBoard()
+
+	first := playPolyglotMoves(t, start, "e2e4", "e7e5", "g1f3")
+	second := playPolyglotMoves(t, start, "g1f3", "e7e5", "e2e4")
+	if keys.key(first) != keys.key(second) {
+		t.Errorf("transposed positions have different keys")
+	}
+	if keys.key(start) != keys.key(playPolyglotMoves(t, start, "g1f3", "g8f6", "f3g1", "f6g8")) {
+		t.Errorf("returning to the start position changed the key")
+	}
+	blackToMove, _ := BoardFromFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq - 0 1")
+	if keys.key(start) == keys.key(blackToMove) {
+		t.Errorf("the side to move did not change the key")
+	}
+
+	//en passant only counts when a pawn can take
+	noCapture := playPolyglotMoves(t, start, "e2e4")
+	noCaptureFEN, _ := BoardFromFEN("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1")
+	if keys.key(noCapture) != keys.key(noCaptureFEN) {
+		t.Errorf("an en passant file counted without a pawn able to take")
+	}
+	capture := playPolyglotMoves(t, start, "e2e4", "a7a6", "e4e5", "d7d5")
+	captureFEN, _ := BoardFromFEN("rnbqkbnr/1pp1pppp/p7/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq - 0 3")
+	if keys.key(capture) == keys.key(captureFEN) {
+		t.Errorf("an en passant file did not count with a pawn able to take")
+	}
+}
+
+func TestPolyglotBookMoves(t *testing.T) {
+	keys := testPolyglotKeys()
+	castling, _ := BoardFromFEN("r3k2r/pppq1ppp/2npbn2/4p3/4P3/2NPBN2/PPPQ1PPP/R3K2R w KQkq - 0 1")
+	startEntry := func(move string, weight uint16) PolyglotEntry {
+		child := playPolyglotMoves(t, NewBoard(), move)
+		return PolyglotEntry{keys.key(NewBoard()), polyglotMove(&child), weight, 0}
+	}
+	entries := []PolyglotEntry{
+		startEntry("e2e4", 3),
+		startEntry("d2d4", 1),
+		startEntry("g1f3", 0),
+		{keys.key(castling), 0x0107, 1, 0},
+	}
+
+	dir, err := ioutil.TempDir("", "book")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := writePolyglotBook(entries, dir+"/book.bin"); err != nil {
+		t.Fatal(err)
+	}
+	book, err := readPolyglotBook(dir+"/book.bin", keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < 200; i++ {
+		counts[book.move(NewBoard()).lastMoveString]++
+	}
+	if counts["Ng1f3 "] > 0 || counts["Pe2e4 "] == 0 || counts["Pd2d4 "] == 0 || counts["Pe2e4 "] < counts["Pd2d4 "] {
+		t.Errorf("book moves were not picked by weight: %v", counts)
+	}
+
+	if move := book.move(castling); move == nil || move.lastMoveString != "O-O" {
+		t.Errorf("e1h1 should be read as castling king side but got %v", move)
+	}
+	if move := book.move(playPolyglotMoves(t, NewBoard(), "e2e4")); move != nil {
+		t.Errorf("played %s from a position that is not in the book", move.lastMoveString)
+	}
+	if move := (*PolyglotBook)(nil).move(NewBoard()); move != nil {
+		t.Errorf("played a move without a book")
+	}
+}
+
+//TestPolyglotStandardKeys checks the keys from the Polyglot specification
+func TestPolyglotStandardKeys(t *testing.T) {
+	games := []struct {
+		moves []string
+		key   uint64
+	}{
+		{[]string{}, 0x463b96181691fc9c},
+		{[]string{"e2e4"}, 0x823c9b50fd114196},
+		{[]string{"e2e4", "d7d5"}, 0x0756b94461c50fb0},
+		{[]string{"e2e4", "d7d5", "e4e5"}, 0x662fafb965db29d4},
+		{[]string{"e2e4", "d7d5", "e4e5", "f7f5"}, 0x22a48b5a8e47ff78},
+		{[]string{"e2e4", "d7d5", "e4e5", "f7f5", "e1e2"}, 0x652a607ca3f242c1},
+		{[]string{"e2e4", "d7d5", "e4e5", "f7f5", "e1e2", "e8f7"}, 0x00fdd303c946bdd9},
+		{[]string{"a2a4", "b7b5", "h2h4", "b5b4", "c2c4"}, 0x3c8123ea7b067637},
+		{[]string{"a2a4", "b7b5", "h2h4", "b5b4", "c2c4", "b4c3", "a1a3"}, 0x5c3f9b829b279560},
+	}
+	for _, game := range games {
+		if key := polyglotKeys.key(playPolyglotMoves(t, NewBoard(), game.moves...)); key != game.key {
+			t.Errorf("%v: expected %016x but got %016x", game.moves, game.key, key)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+//Score is a search value from the side to move's point of view. Mates are scored mateScore less the plies to mate,
+//from the root of the search, so a quicker mate is always worth more than a slower one
+type Score float64
+
+//mateScore is the value of checkmating now. It is far above any evaluation, including a tablebase win
+const mateScore Score = 1000000000
+
+//maxMatePly is the longest mate a score can describe, which is well past any search depth
+const maxMatePly = 1000
+
+//infiniteScore is beyond every real score, for the window a search starts with
+const infiniteScore = mateScore + 1
+
+//matedAt is the score of being checkmated ply plies from the root
+func matedAt(ply int) Score {
+	return -mateScore + Score(ply)
+}
+
+//isMate is true for scores that are a forced mate for either side
+func (score Score) isMate() bool {
+	abs := Score(math.Abs(float64(score)))
+	return abs >= mateScore-maxMatePly && abs <= mateScore
+}
+
+//isDecisive is true for mates and for the infinite scores a search starts with, which pruning should not be based on
+func (score Score) isDecisive() bool {
+	return math.Abs(float64(score)) >= float64(mateScore-maxMatePly)
+}
+
+//matePlies is how many plies from the root the mate a score describes is
+func (score Score) matePlies() int {
+	return int(mateScore - Score(math.Abs(float64(score))))
+}
+
+//mateIn is the number of moves to mate, negative when the side to move is getting mated
+func (score Score) mateIn() int {
+	moves := (score.matePlies() + 1) / 2
+	if score < 0 {
+		return -moves
+	}
+	return moves
+}
+
+//fromPly moves a mate score found ply plies below the root to the root's count. Evaluations score a mate on the
+//board they are given as mateScore, so this is how far the mate really is
+func (score Score) fromPly(ply int) Score {
+	if !score.isMate() {
+		return score
+	}
+	if score > 0 {
+		return score - Score(ply)
+	}
+	return score + Score(ply)
+}
+
+//toPly counts a mate score from ply plies below the root instead of the root, which is how the transposition table
+//stores it, since a position can be reached at different plies
+func (score Score) toPly(ply int) Score {
+	return score.fromPly(-ply)
+}
+
+//String writes a score as "mate N" for mates, and otherwise as a number
+func (score Score) String() string {
+	if score.isMate() {
+		return fmt.Sprintf("mate %d", score.mateIn())
+	}
+	return fmt.Sprintf("%.2f", float64(score))
+}
+
+//evaluationScore turns an evaluation from white's point of view into a score for colour ply plies from the root
+func evaluationScore(value float64, colour Colour, ply int) Score {
+	return (Score(value) * Score(colourOf(colour))).fromPly(ply)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMateScores(t *testing.T) {
+	scores := []struct {
+		score  Score
+		mateIn int
+		text   string
+	}{
+		{-matedAt(1), 1, "mate 1"},
+		{-matedAt(5), 3, "mate 3"},
+		{matedAt(2), -1, "mate -1"},
+		{matedAt(4), -2, "mate -2"},
+		{Score(1.25), 0, "1.25"},
+		{Score(-tablebaseWin), 0, "-100000.00"},
+	}
+	for _, score := range scores {
+		if score.score.isMate() != (score.mateIn != 0) || score.score.isMate() && score.score.mateIn() != score.mateIn || score.score.String() != score.text {
+			t.Errorf("expected %s but got %s", score.text, score.score)
+		}
+	}
+
+	if infiniteScore.isMate() || !infiniteScore.isDecisive() || -matedAt(3) <= -matedAt(5) {
+		t.Errorf("quicker mates should be worth more, and the search window is not a mate")
+	}
+	for ply := 0; ply < 5; ply++ {
+		if matedAt(7).toPly(ply).fromPly(ply) != matedAt(7) || Score(3).toPly(ply) != 3 {
+			t.Errorf("scores did not survive being counted from ply %d", ply)
+		}
+	}
+	if evaluationScore(verySimpleHeuristic(mustFEN(t, "1Q4k1/5ppp/8/8/8/8/8/1R4K1 b - - 0 1")), Black, 3) != matedAt(3) {
+		t.Errorf("a checkmated board should score as mated at its ply")
+	}
+}
+
+func TestSearchPrefersQuickerMate(t *testing.T) {
+	//Qb8 mates at once, and there are slower mates too
+	board := mustFEN(t, "6k1/5ppp/8/8/8/8/1Q6/1R4K1 w - - 0 1")
+	result := newAlphaBetaSearcher(verySimpleHeuristic, nil, newTranspositionTable(1)).search(context.Background(), &board, 4)
+	if result.value != -matedAt(1) || result.bestMove.lastMoveString != "Qb2b8 " {
+		t.Errorf("expected Qb8 mate 1 but got %s %s", result.bestMove.lastMoveString, result.value)
+	}
+}
+
+func mustFEN(t *testing.T, fen string) Board {
+	board, err := BoardFromFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return board
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+//SearchOptions switch parts of the search on and off
+type SearchOptions struct {
+	//QuiescenceDepth is how many captures past the horizon to search, or 0 to evaluate at the horizon
+	QuiescenceDepth int
+	//QuiescenceChecks searches every move out of check past the horizon, rather than standing pat in check
+	QuiescenceChecks bool
+	//DeltaMargin skips captures that would leave the side to move this far below alpha even if nothing recaptured.
+	//0 searches every capture
+	DeltaMargin float64
+	//NullMove lets the side to move pass, and prunes when the search NullMoveReduction plies shallower still fails
+	//high. It is not tried in check, or when the side to move has only pawns, where passing could be its best move
+	NullMove          bool
+	NullMoveReduction int
+	//LateMoveReduction searches quiet moves after the first LateMoveCount one ply shallower, searching them again at
+	//full depth if they beat alpha. It starts LateMoveDepth plies from the horizon
+	LateMoveReduction bool
+	LateMoveCount     int
+	LateMoveDepth     int
+	//FutilityMargin skips quiet moves one ply from the horizon when the evaluation is this far below alpha. 0 searches
+	//every move
+	FutilityMargin float64
+	//CheckExtensions searches moves that give check one ply deeper
+	CheckExtensions bool
+	//Threads is how many goroutines search together, sharing the transposition table. One thread is deterministic
+	Threads int
+	//MultiPV is how many of the best root moves to find a value and principal variation for. 0 is the same as 1
+	MultiPV int
+}
+
+var defaultSearchOptions = SearchOptions{
+	QuiescenceDepth:   8,
+	QuiescenceChecks:  true,
+	DeltaMargin:       2,
+	NullMove:          true,
+	NullMoveReduction: 2,
+	LateMoveReduction: true,
+	LateMoveCount:     3,
+	LateMoveDepth:     3,
+	FutilityMargin:    1.5,
+	CheckExtensions:   true,
+	Threads:           1,
+	MultiPV:           1,
+}
+
+//minimalWindow is the width of the windows searched to test whether a move beats a value, which is smaller than any
+//difference in evaluation
+const minimalWindow = 1e-6
+
+//nullMoveString is the last move of a board where the side to move passed
+const nullMoveString = "--"
+
+//Searcher chooses moves. think searches board within limits, or until ctx is cancelled, and returns the best move it
+//found with its value and principal variation
+type Searcher interface {
+	think(ctx context.Context, board *Board, limits SearchLimits) SearchResult
+}
+
+//AlphaBetaSearcher runs a negamax alpha-beta search. evaluate scores boards from white's point of view. table may be nil
+type AlphaBetaSearcher struct {
+	evaluate  func(board Board) float64
+	tablebase Tablebase
+	table     *TranspositionTable
+	orderer   *MoveOrderer
+	options   SearchOptions
+	nodes     int
+	//rootDepth is the depth of the current iteration, which check extensions cannot go more than twice past
+	rootDepth int
+	limits    SearchLimits
+	deadline  time.Time
+	//canStop is false while the first iteration runs, so there is always a move to play
+	canStop bool
+	stopped bool
+	//abort is set when a helper thread should stop, because the search it helps has finished
+	abort *int32
+	//selDepth is the deepest ply reached, counting quiescence and extensions
+	selDepth int
+	//onInfo is called after every finished iteration, when it is set
+	onInfo func(result SearchResult)
+	//excluded are the root moves already given a line by a MultiPV search, which the search of the next line skips
+	excluded map[string]bool
+	//ctx stops the search as soon as it is cancelled, even during the first iteration
+	ctx context.Context
+	//partial is the best root move of the iteration being searched so far, for when the search is cancelled before
+	//the first iteration finishes
+	partial SearchResult
+}
+
+//SearchResult is the outcome of a search. value is from the side to move's point of view, and pv is the line the
+//search expects, starting with bestMove. depth is the last iteration the search finished, and selDepth the deepest
+//ply it reached. lines are the best root moves with their own values and principal variations, best first, and the
+//first of them is the result itself
+type SearchResult struct {
+	value    Score
+	bestMove *Board
+	pv       []*Board
+	depth    int
+	selDepth int
+	nodes    int
+	elapsed  time.Duration
+	//firstMoveCutoffRate is the share of beta cutoffs the first move searched caused, which measures move ordering
+	firstMoveCutoffRate float64
+	lines               []SearchResult
+}
+
+func newAlphaBetaSearcher(evaluate func(board Board) float64, tablebase Tablebase, table *TranspositionTable) *AlphaBetaSearcher {
+	return &AlphaBetaSearcher{evaluate, tablebase, table, newMoveOrderer(), defaultSearchOptions, 0, 0, SearchLimits{}, time.Time{}, false, false, nil, 0, nil, nil, nil, SearchResult{}}
+}
+
+//search looks depth plies ahead of board. bestMove is nil when there are no moves
+func (searcher *AlphaBetaSearcher) search(ctx context.Context, board *Board, depth int) SearchResult {
+	return searcher.think(ctx, board, SearchLimits{Depth: depth})
+}
+
+//think deepens the search one ply at a time until it reaches a limit, and returns the last iteration it finished. A
+//mate within the depth searched ends the search early, since searching deeper cannot find a quicker one. Cancelling
+//ctx stops the search straight away with the best move found so far, which is nil only when no root move was searched
+func (searcher *AlphaBetaSearcher) think(ctx context.Context, board *Board, limits SearchLimits) SearchResult {
+	start := time.Now()
+	searcher.ctx = ctx
+	searcher.partial = SearchResult{}
+	searcher.nodes = 0
+	searcher.limits = limits
+	searcher.deadline = time.Time{}
+	if moveTime := limits.moveTime(); moveTime > 0 {
+		searcher.deadline = start.Add(moveTime)
+	}
+	searcher.canStop = false
+	searcher.stopped = false
+	searcher.selDepth = 0
+	searcher.table.newSearch()
+	searcher.orderer.newSearch()
+	stopHelpers := searcher.startHelpers(board)
+	lineCount := searcher.lineCount(board)
+
+	result := SearchResult{}
+	for depth := 1; depth <= limits.maxDepth(); depth++ {
+		searcher.rootDepth = depth
+		lines := searcher.searchLines(board, depth, lineCount, start)
+		if searcher.stopped {
+			break
+		}
+		result = lines[0]
+		result.lines = lines
+		value, pv := result.value, result.pv
+		searcher.canStop = true
+		if searcher.onInfo != nil {
+			searcher.onInfo(result)
+		}
+
+		if value.isMate() && value.matePlies() <= depth || len(pv) == 0 || searcher.outOfNodes() {
+			break
+		}
+		//the next iteration takes several times as long as this one, so would not finish
+		if !searcher.deadline.IsZero() && time.Since(start) > searcher.deadline.Sub(start)/2 {
+			break
+		}
+	}
+	if result.depth == 0 && searcher.partial.bestMove != nil {
+		result = searcher.partial
+	}
+	result.nodes = searcher.nodes + stopHelpers()
+	result.elapsed = time.Since(start)
+	result.firstMoveCutoffRate = searcher.orderer.firstMoveCutoffRate()
+	searcher.ctx = nil
+	return result
+}
+
+func (searcher *AlphaBetaSearcher) outOfNodes() bool {
+	return searcher.limits.Nodes > 0 && searcher.nodes >= searcher.limits.Nodes
+}
+
+//shouldStop is true once the search is cancelled or has passed its node count or time
+func (searcher *AlphaBetaSearcher) shouldStop() bool {
+	if searcher.ctx != nil && searcher.ctx.Err() != nil {
+		return true
+	}
+	if !searcher.canStop {
+		return false
+	}
+	if searcher.abort != nil && atomic.LoadInt32(searcher.abort) != 0 {
+		return true
+	}
+	return searcher.outOfNodes() || !searcher.deadline.IsZero() && time.Now().After(searcher.deadline)
+}
+
+//negamax searches board with the window alpha to beta, from the side to move's point of view. It fails soft, so a
+//value outside the window is a bound on the true value rather than the window's edge
+func (searcher *AlphaBetaSearcher) negamax(board *Board, depth, ply int, alpha, beta Score) (Score, []*Board) {
+	searcher.nodes++
+	if searcher.stopped || searcher.shouldStop() {
+		searcher.stopped = true
+		return 0, nil
+	}
+	if ply > searcher.selDepth {
+		searcher.selDepth = ply
+	}
+
+	//tablebase positions have an exact value so are not searched further, except at the root which needs a move
+	if ply > 0 {
+		if wdl, ok := probeTablebase(searcher.tablebase, *board); ok {
+			return evaluationScore(tablebaseValue(wdl, board.colourToMove), board.colourToMove, ply), nil
+		}
+	}
+	if depth == 0 {
+		if searcher.options.QuiescenceDepth > 0 {
+			return searcher.quiesce(board, 0, ply, alpha, beta)
+		}
+		return searcher.staticValue(board, ply), nil
+	}
+	if ply > 0 && board.winner == Stalemate {
+		return 0, nil
+	}
+
+	key := searchKeys.key(*board)
+	hashMove := uint16(0)
+	if entry, ok := searcher.table.probe(key); ok {
+		hashMove = entry.move
+		value := entry.value.fromPly(ply)
+		//exact values would cut the principal variation short, so they are only used off it
+		pvNode := beta-alpha > minimalWindow
+		if ply > 0 && int(entry.depth) >= depth {
+			if entry.bound == ExactBound && !pvNode || entry.bound == LowerBound && value >= beta || entry.bound == UpperBound && value <= alpha {
+				return value, nil
+			}
+		}
+	}
+
+	inCheck := board.isChecked()
+	options := searcher.options
+	if options.NullMove && ply > 0 && !inCheck && depth > options.NullMoveReduction && board.lastMoveString != nullMoveString &&
+		hasPieces(board, board.colourToMove) && !beta.isDecisive() {
+		null := board.nullMove()
+		value, _ := searcher.negamax(&null, depth-1-options.NullMoveReduction, ply+1, -beta, -beta+minimalWindow)
+		if searcher.stopped {
+			return 0, nil
+		}
+		//passing cannot prove a mate, so a mate found after passing only shows the move fails high
+		if -value >= beta {
+			if (-value).isMate() {
+				return beta, nil
+			}
+			return -value, nil
+		}
+	}
+
+	futile := false
+	futilityValue := Score(0)
+	if options.FutilityMargin > 0 && depth == 1 && ply > 0 && !inCheck {
+		futilityValue = evaluationScore(searcher.evaluate(*board), board.colourToMove, ply) + Score(options.FutilityMargin)
+		futile = futilityValue <= alpha && !futilityValue.isDecisive()
+	}
+
+	moves := board.getPossibleMoves()
+	if ply == 0 && len(searcher.excluded) > 0 {
+		moves = searcher.withoutExcluded(moves)
+	}
+	moves = searcher.orderer.order(board, moves, ply, hashMove)
+	if len(moves) == 0 {
+		if inCheck {
+			return matedAt(ply), nil
+		}
+		return 0, nil
+	}
+
+	originalAlpha := alpha
+	best := -infiniteScore
+	var pv []*Board
+	for i, child := range moves {
+		quiet := !isTactical(board, child) && !child.isChecked()
+		if futile && quiet {
+			if futilityValue > best {
+				best = futilityValue
+			}
+			continue
+		}
+
+		newDepth := depth - 1
+		if options.CheckExtensions && child.isChecked() && ply < 2*searcher.rootDepth {
+			newDepth++
+		}
+
+		var value Score
+		var childPV []*Board
+		reduced := options.LateMoveReduction && quiet && !inCheck && i >= options.LateMoveCount && depth >= options.LateMoveDepth &&
+			!alpha.isDecisive()
+		if reduced {
+			value, childPV = searcher.negamax(child, newDepth-1, ply+1, -alpha-minimalWindow, -alpha)
+			value = -value
+		}
+		if !reduced || value > alpha && !searcher.stopped {
+			value, childPV = searcher.negamax(child, newDepth, ply+1, -beta, -alpha)
+			value = -value
+		}
+		if searcher.stopped {
+			return 0, nil
+		}
+		if value > best || pv == nil && value >= best {
+			best = value
+			pv = append([]*Board{child}, childPV...)
+			if ply == 0 && len(searcher.excluded) == 0 {
+				searcher.partial = SearchResult{best, child, pv, 0, searcher.selDepth, 0, 0, 0, nil}
+			}
+		}
+		if best > alpha {
+			alpha = best
+		}
+		if alpha >= beta {
+			searcher.orderer.cutoff(board, child, ply, depth, i)
+			break
+		}
+	}
+
+	bound := ExactBound
+	if best <= originalAlpha {
+		bound = UpperBound
+	} else if best >= beta {
+		bound = LowerBound
+	}
+	move := uint16(0)
+	if len(pv) > 0 {
+		move = polyglotMove(pv[0])
+	}
+	//the value of a root searched without some of its moves is not the root's value
+	if ply > 0 || len(searcher.excluded) == 0 {
+		searcher.table.store(key, depth, bound, best.toPly(ply), move)
+	}
+	return best, pv
+}
+
+//nullMove passes the move to the other side
+func (boardState Board) nullMove() Board {
+	null := boardState.clone()
+	null.colourToMove = boardState.colourToMove.opposite()
+	null.enPassantRank = -1
+	null.lastMoveString = nullMoveString
+	null.children = nil
+	return null
+}
+
+//hasPieces is true when colour has something other than its king and pawns, on the board or in its pocket
+func hasPieces(board *Board, colour Colour) bool {
+	for _, piece := range board.pieces {
+		if piece.colour == colour && piece.pieceType.sign != "K" && piece.pieceType.sign != "P" {
+			return true
+		}
+	}
+	pocket := board.whitePocket
+	if colour == Black {
+		pocket = board.blackPocket
+	}
+	for _, count := range pocket {
+		if count > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+//quiesce carries on searching captures and promotions from the horizon until the position is quiet, so the search does
+//not stop halfway through an exchange. The side to move can stand pat on the evaluation instead of capturing, unless
+//it is in check
+func (searcher *AlphaBetaSearcher) quiesce(board *Board, qDepth, ply int, alpha, beta Score) (Score, []*Board) {
+	if qDepth > 0 {
+		searcher.nodes++
+		if searcher.stopped || searcher.shouldStop() {
+			searcher.stopped = true
+			return 0, nil
+		}
+		if ply > searcher.selDepth {
+			searcher.selDepth = ply
+		}
+		if wdl, ok := probeTablebase(searcher.tablebase, *board); ok {
+			return evaluationScore(tablebaseValue(wdl, board.colourToMove), board.colourToMove, ply), nil
+		}
+	}
+	if board.winner == Stalemate {
+		return 0, nil
+	}
+
+	standPat := searcher.staticValue(board, ply)
+	inCheck := searcher.options.QuiescenceChecks && board.isChecked()
+	if qDepth >= searcher.options.QuiescenceDepth || standPat.isMate() || !inCheck && standPat >= beta {
+		return standPat, nil
+	}
+
+	best := standPat
+	if inCheck {
+		best = -infiniteScore
+	} else if standPat > alpha {
+		alpha = standPat
+	}
+
+	moves := searcher.orderer.order(board, board.getPossibleMoves(), -1, 0)
+	if inCheck && len(moves) == 0 {
+		return matedAt(ply), nil
+	}
+	var pv []*Board
+	for _, child := range moves {
+		if !inCheck {
+			if !isTactical(board, child) {
+				continue
+			}
+			gain := Score((materialValue(*child) - materialValue(*board)) * colourOf(board.colourToMove))
+			if searcher.options.DeltaMargin > 0 && standPat+gain+Score(searcher.options.DeltaMargin) <= alpha {
+				continue
+			}
+		}
+
+		value, childPV := searcher.quiesce(child, qDepth+1, ply+1, -beta, -alpha)
+		if searcher.stopped {
+			return 0, nil
+		}
+		value = -value
+		if value > best || pv == nil && inCheck {
+			best = value
+			pv = append([]*Board{child}, childPV...)
+		}
+		if best > alpha {
+			alpha = best
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	return best, pv
+}
+
+//staticValue evaluates a board at the horizon for the side to move. Evaluators do not look for the end of the game, so
+//a side without a legal move is scored here as mated or stalemated
+func (searcher *AlphaBetaSearcher) staticValue(board *Board, ply int) Score {
+	if !board.hasPossibleBoardMoves() {
+		if board.isChecked() {
+			return matedAt(ply)
+		}
+		return 0
+	}
+	return evaluationScore(searcher.evaluate(*board), board.colourToMove, ply)
+}
+
+//isTactical is true for captures and promotions
+func isTactical(board, child *Board) bool {
+	return len(child.pieces) < len(board.pieces) || strings.Contains(child.lastMoveString, "=")
+}
+
+//colourOf is 1 for white and -1 for black, to turn values from white's point of view into the side to move's
+func colourOf(colour Colour) float64 {
+	if colour == Black {
+		return -1.0
+	}
+	return 1.0
+}
+
+//nps is how many nodes a result searched per second
+func (result SearchResult) nps() int {
+	if result.elapsed <= 0 {
+		return 0
+	}
+	return int(float64(result.nodes) / result.elapsed.Seconds())
+}
+
+//String reports a result as "depth 4 seldepth 9 score 0.30 nodes 5120 nps 2048 time 2.5s pv Pe2e4 Pe7e5 ..."
+func (result SearchResult) String() string {
+	info := fmt.Sprintf("depth %d seldepth %d score %s nodes %d nps %d time %s", result.depth, result.selDepth, result.value,
+		result.nodes, result.nps(), result.elapsed.Round(time.Millisecond))
+	//tree searches have no cutoffs
+	if result.firstMoveCutoffRate > 0 {
+		info += fmt.Sprintf(" firstcutoff %.2f", result.firstMoveCutoffRate)
+	}
+	return info + " pv " + pvString(result.pv)
+}
+
+//pvString writes a line of moves the way game records do
+func pvString(pv []*Board) string {
+	moves := []string{}
+	for _, board := range pv {
+		moves = append(moves, moveName(board))
+	}
+	return strings.Join(moves, " ")
+}
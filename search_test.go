@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+//plainMinimax searches every move without pruning, giving the value alpha-beta must agree with
+func plainMinimax(board *Board, depth, ply int, evaluate func(board Board) float64) Score {
+	if depth == 0 {
+		return evaluationScore(evaluate(*board), board.colourToMove, ply)
+	}
+	if ply > 0 && board.winner == Stalemate {
+		return 0
+	}
+	moves := board.getPossibleMoves()
+	if len(moves) == 0 {
+		if board.isChecked() {
+			return matedAt(ply)
+		}
+		return 0
+	}
+	best := -infiniteScore
+	for _, child := range moves {
+		if value := -plainMinimax(child, depth-1, ply+1, evaluate); value > best {
+			best = value
+		}
+	}
+	return best
+}
+
+var searchPositions = []struct {
+	fen   string
+	depth int
+}{
+	{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 3},
+	//white can win a knight
+	{"r1bqkbnr/pppp1ppp/2n5/4p3/3PP3/8/PPP2PPP/RNBQKBNR w KQkq - 0 3", 2},
+	//mate in two
+	{"r5k1/5ppp/8/8/8/8/1Q6/1R4K1 w - - 0 1", 3},
+	{"8/8/4k3/8/2p5/8/B2K4/8 b - - 0 1", 4},
+	{"8/5pk1/6p1/8/8/6P1/5PK1/8 w - - 0 1", 4},
+	//stalemate traps
+	{"7k/5Q2/6K1/8/8/8/8/8 w - - 0 1", 3},
+}
+
+func TestNegamaxMatchesMinimax(t *testing.T) {
+	for _, position := range searchPositions {
+		board, _ := BoardFromFEN(position.fen)
+		expected := plainMinimax(&board, position.depth, 0, verySimpleHeuristic)
+		//minimax stops at the horizon, so quiescence is left out
+		searcher := newAlphaBetaSearcher(verySimpleHeuristic, nil, nil)
+		searcher.options = SearchOptions{}
+		result := searcher.search(context.Background(), &board, position.depth)
+		if result.value != expected {
+			t.Errorf("%s: minimax gives %f but alpha-beta gives %f", position.fen, expected, result.value)
+		}
+		if result.bestMove == nil || len(result.pv) == 0 || result.pv[0] != result.bestMove {
+			t.Errorf("%s: no best move or principal variation", position.fen)
+			continue
+		}
+
+		//the principal variation leads to a position worth the root value
+		last := result.pv[len(result.pv)-1]
+		value := evaluationScore(verySimpleHeuristic(*last), board.colourToMove, len(result.pv))
+		if len(result.pv) == position.depth && value != result.value {
+			t.Errorf("%s: the principal variation ends at %f but the search found %f", position.fen, value, result.value)
+		}
+	}
+}
+
+func TestNegamaxFindsMate(t *testing.T) {
+	board, _ := BoardFromFEN("r5k1/5ppp/8/8/8/8/1Q6/1R4K1 w - - 0 1")
+	result := newAlphaBetaSearcher(verySimpleHeuristic, nil, nil).search(context.Background(), &board, 3)
+	if result.value.mateIn() != 2 || len(result.pv) != 3 || result.value.String() != "mate 2" {
+		t.Fatalf("expected mate in two but got %s", result.value)
+	}
+	if result.pv[0].lastMoveString != "Qb2b8 " || result.pv[2].lastMoveString != "Rb1b8 " {
+		t.Errorf("expected Qb8+ Rxb8# but got %s ... %s", result.pv[0].lastMoveString, result.pv[2].lastMoveString)
+	}
+}
+
+//materialOnly counts material without looking for mates, as evolved heuristics do
+func materialOnly(board Board) float64 {
+	return materialValue(board)
+}
+
+func TestQuiescenceFindsMate(t *testing.T) {
+	searcher := newAlphaBetaSearcher(materialOnly, nil, nil)
+	mated := mustFEN(t, "R5k1/5ppp/8/8/8/8/8/6K1 b - - 0 1")
+	if value, _ := searcher.quiesce(&mated, 1, 3, -infiniteScore, infiniteScore); value != matedAt(3) {
+		t.Errorf("expected a mated score of %s but got %s", matedAt(3), value)
+	}
+
+	//the mate is past the horizon of a one ply search, so only quiescence sees it
+	board := mustFEN(t, "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1")
+	result := newAlphaBetaSearcher(materialOnly, nil, nil).search(context.Background(), &board, 1)
+	if result.value.String() != "mate 1" || result.pv[0].lastMoveString != "Ra1a8 " {
+		t.Errorf("expected Ra8# to be mate 1 but got %s", result)
+	}
+}
+
+func TestThinkStopsAtLimits(t *testing.T) {
+	board := NewBoard()
+	searcher := newAlphaBetaSearcher(verySimpleHeuristic, nil, nil)
+
+	result := searcher.think(context.Background(), &board, SearchLimits{Nodes: 500})
+	if result.bestMove == nil || result.depth < 1 || result.nodes < 500 {
+		t.Errorf("expected a move after 500 nodes but got depth %d after %d nodes", result.depth, result.nodes)
+	}
+	//the unfinished iteration is thrown away
+	if full := newAlphaBetaSearcher(verySimpleHeuristic, nil, nil).search(context.Background(), &board, result.depth); full.value != result.value || full.bestMove.lastMoveString != result.bestMove.lastMoveString {
+		t.Errorf("the node limited search did not return its last finished iteration")
+	}
+
+	started := time.Now()
+	result = searcher.think(context.Background(), &board, SearchLimits{Time: 50 * time.Millisecond})
+	if result.bestMove == nil || time.Since(started) > 500*time.Millisecond {
+		t.Errorf("expected a move within 50ms but took %s", time.Since(started))
+	}
+
+	//the first iteration always finishes, so there is a move even without time
+	result = searcher.think(context.Background(), &board, SearchLimits{Time: time.Nanosecond})
+	if result.bestMove == nil || result.depth != 1 {
+		t.Errorf("expected a depth 1 move but got depth %d", result.depth)
+	}
+}
+
+func TestSearchWithTranspositionTable(t *testing.T) {
+	//king moves transpose into each other after two moves each
+	for _, fen := range []string{"8/5pk1/6p1/8/8/6P1/5PK1/8 w - - 0 1", "8/8/4k3/8/2p5/8/B2K4/8 b - - 0 1"} {
+		board, _ := BoardFromFEN(fen)
+		plain := newAlphaBetaSearcher(verySimpleHeuristic, nil, nil).search(context.Background(), &board, 4)
+		searcher := newAlphaBetaSearcher(verySimpleHeuristic, nil, newTranspositionTable(1))
+		hashed := searcher.search(context.Background(), &board, 4)
+		if hashed.value != plain.value || hashed.bestMove == nil {
+			t.Errorf("%s: expected %f but the table gave %f", fen, plain.value, hashed.value)
+		}
+		if hashed.nodes >= plain.nodes {
+			t.Errorf("%s: the table did not save any nodes: %d against %d", fen, hashed.nodes, plain.nodes)
+		}
+		if again := searcher.search(context.Background(), &board, 4); again.nodes >= hashed.nodes {
+			t.Errorf("%s: searching again did not use the table", fen)
+		}
+	}
+}
+
+func TestQuiescenceSeesRecaptures(t *testing.T) {
+	//the d5 pawn is defended, so the queen is lost if it takes
+	board, _ := BoardFromFEN("4k3/8/4p3/3p4/8/8/8/3QK3 w - - 0 1")
+	searcher := newAlphaBetaSearcher(verySimpleHeuristic, nil, nil)
+	searcher.options = SearchOptions{}
+	if result := searcher.search(context.Background(), &board, 1); result.bestMove.lastMoveString != "Qd1d5 " {
+		t.Errorf("expected a search without quiescence to take the pawn but it played %s", result.bestMove.lastMoveString)
+	}
+
+	for _, options := range []SearchOptions{defaultSearchOptions, {QuiescenceDepth: 2}} {
+		searcher.options = options
+		result := searcher.search(context.Background(), &board, 1)
+		if result.bestMove.lastMoveString == "Qd1d5 " || result.value < 0 {
+			t.Errorf("%+v: took a defended pawn with the queen, valuing it at %f", options, result.value)
+		}
+	}
+
+	//Nc7+ forks the king and rook, which only shows when black has to move out of check rather than stand pat
+	board, _ = BoardFromFEN("r3k3/8/8/3N4/8/8/8/4K3 w - - 0 1")
+	searcher.options = SearchOptions{QuiescenceDepth: 4, QuiescenceChecks: true}
+	withChecks := searcher.search(context.Background(), &board, 1)
+	searcher.options = SearchOptions{QuiescenceDepth: 4}
+	withoutChecks := searcher.search(context.Background(), &board, 1)
+	if withChecks.bestMove.lastMoveString != "Nd5c7 " || withChecks.value <= withoutChecks.value {
+		t.Errorf("expected searching check evasions to find the fork, but got %s %f against %f", withChecks.bestMove.lastMoveString, withChecks.value, withoutChecks.value)
+	}
+}
+
+func TestSelectiveSearch(t *testing.T) {
+	quiescence := SearchOptions{QuiescenceDepth: 8, QuiescenceChecks: true, DeltaMargin: 2}
+	nullMove, lateMoves, futility, checks := quiescence, quiescence, quiescence, quiescence
+	nullMove.NullMove, nullMove.NullMoveReduction = true, 2
+	lateMoves.LateMoveReduction, lateMoves.LateMoveCount, lateMoves.LateMoveDepth = true, 3, 3
+	futility.FutilityMargin = 1.5
+	checks.CheckExtensions = true
+
+	positions := []struct {
+		fen  string
+		move string
+	}{
+		{"r5k1/5ppp/8/8/8/8/1Q6/1R4K1 w - - 0 1", "Qb2b8 "},
+		{"r3k3/8/8/3N4/8/8/8/4K3 w - - 0 1", "Nd5c7 "},
+		{"4k3/8/8/3q4/8/8/8/3RK3 w - - 0 1", "Rd1d5 "},
+	}
+	for _, options := range []SearchOptions{quiescence, nullMove, lateMoves, futility, checks, defaultSearchOptions} {
+		for _, position := range positions {
+			board, _ := BoardFromFEN(position.fen)
+			searcher := newAlphaBetaSearcher(verySimpleHeuristic, nil, newTranspositionTable(1))
+			searcher.options = options
+			if result := searcher.search(context.Background(), &board, 4); result.bestMove.lastMoveString != position.move {
+				t.Errorf("%+v: expected %s in %s but got %s", options, position.move, position.fen, result.bestMove.lastMoveString)
+			}
+		}
+	}
+
+	board, _ := BoardFromFEN("r1bqkbnr/pppp1ppp/2n5/4p3/3PP3/8/PPP2PPP/RNBQKBNR w KQkq - 0 3")
+	searcher := newAlphaBetaSearcher(verySimpleHeuristic, nil, newTranspositionTable(1))
+	searcher.options = quiescence
+	full := searcher.search(context.Background(), &board, 3)
+	searcher = newAlphaBetaSearcher(verySimpleHeuristic, nil, newTranspositionTable(1))
+	selective := searcher.search(context.Background(), &board, 3)
+	if selective.nodes >= full.nodes {
+		t.Errorf("expected fewer than %d nodes but searched %d", full.nodes, selective.nodes)
+	}
+}
+
+func TestNullMove(t *testing.T) {
+	board, _ := BoardFromFEN("4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1")
+	null := board.nullMove()
+	if null.colourToMove != Black || null.enPassantRank != -1 || len(null.getPossibleMoves()) != 6 {
+		t.Errorf("passing should give black the move without en passant, but got %s", null.ToFEN())
+	}
+	if hasPieces(&board, White) {
+		t.Errorf("a king and pawn ending has no pieces to guard against zugzwang")
+	}
+	crazyhouse, _ := BoardFromFEN("4k3/8/8/8/8/8/8/4K3[N] w - - 0 1")
+	if !hasPieces(&crazyhouse, White) {
+		t.Errorf("a knight in the pocket counts as a piece")
+	}
+}
+
+func TestSearchInfo(t *testing.T) {
+	board := NewBoard()
+	searcher := newAlphaBetaSearcher(verySimpleHeuristic, nil, newTranspositionTable(1))
+	infos := []SearchResult{}
+	searcher.onInfo = func(result SearchResult) { infos = append(infos, result) }
+
+	result := searcher.search(context.Background(), &board, 4)
+	if len(infos) != 4 {
+		t.Fatalf("expected an info for each of 4 iterations but got %d", len(infos))
+	}
+	for i, info := range infos {
+		if info.depth != i+1 || info.selDepth < info.depth || info.nodes <= 0 || info.elapsed <= 0 {
+			t.Errorf("iteration %d reported depth %d, seldepth %d, %d nodes in %s", i+1, info.depth, info.selDepth, info.nodes, info.elapsed)
+		}
+		//the transposition table does not cut the principal variation short
+		if len(info.pv) != info.depth {
+			t.Errorf("iteration %d reported a %d move principal variation: %s", i+1, len(info.pv), pvString(info.pv))
+		}
+	}
+	if last := infos[3]; last.value != result.value || last.bestMove != result.bestMove {
+		t.Errorf("the last info %s is not the result %s", last, result)
+	}
+
+	board = mustFEN(t, "r5k1/5ppp/8/8/8/8/1Q6/1R4K1 w - - 0 1")
+	result = newAlphaBetaSearcher(verySimpleHeuristic, nil, nil).search(context.Background(), &board, 3)
+	if line := result.String(); !strings.Contains(line, "score mate 2") || !strings.HasSuffix(line, "pv Qb2b8 Ra8b8 Rb1b8") {
+		t.Errorf("expected the mate in two but got %s", line)
+	}
+}
+
+//countdownContext is cancelled after it has been checked calls times, so a search can be cancelled at a repeatable
+//point
+type countdownContext struct {
+	context.Context
+	calls int
+}
+
+func (ctx *countdownContext) Err() error {
+	ctx.calls--
+	if ctx.calls < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestThinkCancels(t *testing.T) {
+	board := mustFEN(t, "r1bqkbnr/pppp1ppp/2n5/4p3/3PP3/8/PPP2PPP/RNBQKBNR w KQkq - 0 3")
+	legal := map[string]bool{}
+	for _, child := range board.getPossibleMoves() {
+		legal[moveName(child)] = true
+	}
+
+	//cancelled part way through the first iteration, the best root move so far is played
+	result := newAlphaBetaSearcher(verySimpleHeuristic, nil, nil).think(&countdownContext{context.Background(), 20}, &board, SearchLimits{})
+	if result.bestMove == nil || !legal[moveName(result.bestMove)] || result.depth != 0 {
+		t.Errorf("expected a move from the unfinished first iteration but got depth %d", result.depth)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	result = newAlphaBetaSearcher(verySimpleHeuristic, nil, newTranspositionTable(1)).think(ctx, &board, SearchLimits{})
+	if time.Since(started) > time.Second || result.bestMove == nil || !legal[moveName(result.bestMove)] {
+		t.Errorf("expected a move soon after the deadline but took %s", time.Since(started))
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if result := newAlphaBetaSearcher(verySimpleHeuristic, nil, nil).think(cancelled, &board, SearchLimits{}); result.bestMove != nil {
+		t.Errorf("a search cancelled before it started played %s", moveName(result.bestMove))
+	}
+}
@@ -0,0 +1,1031 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//WDL is a tablebase win/draw/loss value for the side to move. Cursed wins and blessed losses are results that are
+//drawn by the fifty move rule
+type WDL int
+
+const (
+	//WDLLoss is a loss
+	WDLLoss WDL = -2
+	//WDLBlessedLoss is a loss that is saved by the fifty move rule
+	WDLBlessedLoss WDL = -1
+	//WDLDraw is a draw
+	WDLDraw WDL = 0
+	//WDLCursedWin is a win that is spoilt by the fifty move rule
+	WDLCursedWin WDL = 1
+	//WDLWin is a win
+	WDLWin WDL = 2
+)
+
+const (
+	syzygyFlagSTM         = 1
+	syzygyFlagMapped      = 2
+	syzygyFlagWinPlies    = 4
+	syzygyFlagLossPlies   = 8
+	syzygyFlagWide        = 16
+	syzygyFlagSingleValue = 128
+)
+
+var syzygyWDLMagic = []byte{0x71, 0xE8, 0x23, 0x5D}
+var syzygyDTZMagic = []byte{0xD7, 0x66, 0x0C, 0xA5}
+
+type syzygyResult int
+
+const (
+	syzygyOK syzygyResult = iota
+	syzygyFail
+	syzygyChangeSTM
+	syzygyZeroingBestMove
+)
+
+//syzygyPieceCodes are the piece codes used in the table files. Black pieces have 8 added
+var syzygyPieceCodes = map[string]int{"P": 1, "N": 2, "B": 3, "R": 4, "Q": 5, "K": 6}
+
+//syzygyPieceOrder is the order pieces are listed in within a table name
+var syzygyPieceOrder = []string{"K", "Q", "R", "B", "N", "P"}
+
+//syzygyIndexTables hold the square mappings used to turn a position into a table index
+type syzygyIndexTables struct {
+	mapPawns      [64]int
+	mapB1H1H7     [64]int
+	mapA1D1D4     [64]int
+	mapKK         [10][64]int
+	binomial      [6][64]uint64
+	leadPawnIdx   [6][64]uint64
+	leadPawnsSize [6][4]uint64
+}
+
+var syzygyIndexes = newSyzygyIndexTables()
+
+func offA1H8(square int) int {
+	return square>>3 - square&7
+}
+
+func newSyzygyIndexTables() *syzygyIndexTables {
+	tables := &syzygyIndexTables{}
+
+	//mapB1H1H7 numbers the squares below the a1-h8 diagonal from 0 to 27
+	code := 0
+	for square := 0; square < 64; square++ {
+		if offA1H8(square) < 0 {
+			tables.mapB1H1H7[square] = code
+			code++
+		}
+	}
+
+	//mapA1D1D4 numbers the squares of the a1-d1-d4 triangle from 0 to 9, with the diagonal squares last
+	code = 0
+	diagonal := []int{}
+	for square := 0; square <= 27; square++ {
+		if offA1H8(square) < 0 && square&7 <= 3 {
+			tables.mapA1D1D4[square] = code
+			code++
+		} else if offA1H8(square) == 0 && square&7 <= 3 {
+			diagonal = append(diagonal, square)
+		}
+	}
+	for _, square := range diagonal {
+		tables.mapA1D1D4[square] = code
+		code++
+	}
+
+	//mapKK numbers the 462 legal placements of two kings with the first in the a1-d1-d4 triangle, and the second
+	//not above the diagonal when the first is on it
+	type kingPair struct{ idx, square int }
+	bothOnDiagonal := []kingPair{}
+	code = 0
+	for idx := 0; idx < 10; idx++ {
+		for s1 := 0; s1 <= 27; s1++ {
+			if tables.mapA1D1D4[s1] != idx || (idx == 0 && s1 != 1) {
+				continue
+			}
+			for s2 := 0; s2 < 64; s2++ {
+				fileDistance := s1&7 - s2&7
+				rankDistance := s1>>3 - s2>>3
+				if fileDistance >= -1 && fileDistance <= 1 && rankDistance >= -1 && rankDistance <= 1 {
+					continue
+				}
+				if offA1H8(s1) == 0 && offA1H8(s2) > 0 {
+					continue
+				}
+				if offA1H8(s1) == 0 && offA1H8(s2) == 0 {
+					bothOnDiagonal = append(bothOnDiagonal, kingPair{idx, s2})
+					continue
+				}
+				tables.mapKK[idx][s2] = code
+				code++
+			}
+		}
+	}
+	for _, pair := range bothOnDiagonal {
+		tables.mapKK[pair.idx][pair.square] = code
+		code++
+	}
+
+	tables.binomial[0][0] = 1
+	for n := 1; n < 64; n++ {
+		for k := 0; k < 6 && k <= n; k++ {
+			if k > 0 {
+				tables.binomial[k][n] += tables.binomial[k-1][n-1]
+			}
+			if k < n {
+				tables.binomial[k][n] += tables.binomial[k][n-1]
+			}
+		}
+	}
+
+	//mapPawns numbers the squares a2-h7 so that the pawn with the highest number is the leading pawn, nearest the edge
+	//and lowest among pawns on the same file
+	availableSquares := 47
+	for leadPawnsCnt := 1; leadPawnsCnt <= 5; leadPawnsCnt++ {
+		for file := 0; file <= 3; file++ {
+			idx := uint64(0)
+			for rank := 1; rank <= 6; rank++ {
+				square := rank*8 + file
+				if leadPawnsCnt == 1 {
+					tables.mapPawns[square] = availableSquares
+					availableSquares--
+					tables.mapPawns[square^7] = availableSquares
+					availableSquares--
+				}
+				tables.leadPawnIdx[leadPawnsCnt][square] = idx
+				idx += tables.binomial[leadPawnsCnt-1][tables.mapPawns[square]]
+			}
+			tables.leadPawnsSize[leadPawnsCnt][file] = idx
+		}
+	}
+
+	return tables
+}
+
+//syzygyPairsData describes one compressed subtable. Positions are offsets into the table file
+type syzygyPairsData struct {
+	flags           byte
+	pieces          [7]int
+	groupLen        [8]int
+	groupIdx        [8]uint64
+	sizeofBlock     uint64
+	span            uint64
+	sparseIndexSize uint64
+	numBlocks       uint64
+	blocksNum       uint64
+	maxSymLen       int
+	minSymLen       int
+	lowestSym       int
+	base64          []uint64
+	symlen          []int
+	btree           int
+	sparseIndex     int
+	blockLength     int
+	data            int
+	mapIdx          [4]int
+}
+
+type syzygyTable struct {
+	name            string
+	isDTZ           bool
+	raw             []byte
+	pieceCount      int
+	hasPawns        bool
+	hasUniquePieces bool
+	symmetric       bool
+	pawnCount       [2]int
+	items           [2][4]*syzygyPairsData
+	dtzMap          int
+}
+
+//SyzygyTablebase probes Syzygy WDL (.rtbw) and DTZ (.rtbz) files from a directory. Tables are read when first needed
+type SyzygyTablebase struct {
+	dir       string
+	maxPieces int
+	names     map[string]bool
+	tables    map[string]*syzygyTable
+	//lock guards tables, which parallel searches read in as they probe
+	lock sync.Mutex
+}
+
+//loadSyzygy finds the tables in dir. A directory without tables gives a tablebase that never probes
+func loadSyzygy(dir string) *SyzygyTablebase {
+	tablebase := &SyzygyTablebase{dir, 0, map[string]bool{}, map[string]*syzygyTable{}, sync.Mutex{}}
+	files, _ := ioutil.ReadDir(dir)
+	for _, file := range files {
+		name := strings.TrimSuffix(file.Name(), ".rtbw")
+		if name == file.Name() || !strings.Contains(name, "v") {
+			continue
+		}
+		tablebase.names[name] = true
+		if pieceCount := len(name) - 1; pieceCount > tablebase.maxPieces {
+			tablebase.maxPieces = pieceCount
+		}
+	}
+	return tablebase
+}
+
+//canProbe is whether the board is a standard position with few enough pieces and no castling rights
+func (tablebase *SyzygyTablebase) canProbe(board Board) bool {
+	return tablebase != nil && board.variant != Crazyhouse && len(board.pieces) <= tablebase.maxPieces &&
+		!board.canWhiteKingSideCastle && !board.canWhiteQueenSideCastle && !board.canBlackKingSideCastle && !board.canBlackQueenSideCastle
+}
+
+//ProbeWDL gives the tablebase result for the side to move, or false when the position is not covered
+func (tablebase *SyzygyTablebase) ProbeWDL(board Board) (WDL, bool) {
+	if !tablebase.canProbe(board) {
+		return WDLDraw, false
+	}
+	wdl, result := tablebase.search(board, false)
+	return wdl, result != syzygyFail
+}
+
+//ProbeDTZ gives the number of plies to the next capture or pawn move in the fastest win, or slowest loss, for the side
+//to move. It is positive when winning, negative when losing and 0 for draws. Wins and losses spoilt by the fifty move
+//rule are given 100 extra plies
+func (tablebase *SyzygyTablebase) ProbeDTZ(board Board) (int, bool) {
+	if !tablebase.canProbe(board) {
+		return 0, false
+	}
+	dtz, result := tablebase.probeDTZ(board)
+	return dtz, result != syzygyFail
+}
+
+func isZeroingMove(boardState Board, child *Board) bool {
+	return len(child.pieces) < len(boardState.pieces) || strings.HasPrefix(child.lastMoveString, "P")
+}
+
+//search resolves captures, and pawn moves when checkZeroingMoves is set, before probing the WDL table, since the
+//tables do not hold positions where a capture is the best move or where en passant is possible
+func (tablebase *SyzygyTablebase) search(board Board, checkZeroingMoves bool) (WDL, syzygyResult) {
+	children := board.getPossibleMoves()
+	if len(children) == 0 {
+		if board.isChecked() {
+			return WDLLoss, syzygyOK
+		}
+		return WDLDraw, syzygyOK
+	}
+
+	bestValue := WDLLoss
+	moveCount := 0
+	for _, child := range children {
+		if len(child.pieces) == len(board.pieces) && (!checkZeroingMoves || !strings.HasPrefix(child.lastMoveString, "P")) {
+			continue
+		}
+		moveCount++
+
+		value, result := tablebase.search(*child, false)
+		value = -value
+		if result == syzygyFail {
+			return WDLDraw, syzygyFail
+		}
+		if value > bestValue {
+			bestValue = value
+			if value >= WDLWin {
+				return value, syzygyZeroingBestMove
+			}
+		}
+	}
+
+	noMoreMoves := moveCount > 0 && moveCount == len(children)
+	value := bestValue
+	if !noMoreMoves {
+		tableValue, result := tablebase.probeTable(board, false, WDLDraw)
+		if result == syzygyFail {
+			return WDLDraw, syzygyFail
+		}
+		value = WDL(tableValue)
+	}
+
+	if bestValue >= value {
+		if bestValue > WDLDraw || noMoreMoves {
+			return bestValue, syzygyZeroingBestMove
+		}
+		return bestValue, syzygyOK
+	}
+	return value, syzygyOK
+}
+
+func dtzBeforeZeroing(wdl WDL) int {
+	switch wdl {
+	case WDLWin:
+		return 1
+	case WDLCursedWin:
+		return 101
+	case WDLBlessedLoss:
+		return -101
+	case WDLLoss:
+		return -1
+	}
+	return 0
+}
+
+func signOf(value int) int {
+	if value > 0 {
+		return 1
+	}
+	if value < 0 {
+		return -1
+	}
+	return 0
+}
+
+func (tablebase *SyzygyTablebase) probeDTZ(board Board) (int, syzygyResult) {
+	wdl, result := tablebase.search(board, true)
+	if result == syzygyFail || wdl == WDLDraw {
+		return 0, result
+	}
+	if result == syzygyZeroingBestMove {
+		return dtzBeforeZeroing(wdl), syzygyOK
+	}
+
+	dtz, result := tablebase.probeTable(board, true, wdl)
+	if result == syzygyFail {
+		return 0, syzygyFail
+	}
+	if result != syzygyChangeSTM {
+		if wdl == WDLBlessedLoss || wdl == WDLCursedWin {
+			dtz += 100
+		}
+		return dtz * signOf(int(wdl)), syzygyOK
+	}
+
+	//the table only holds the other side to move, so find the best move with a one ply search
+	minDTZ := 0xFFFF
+	for _, child := range board.getPossibleMoves() {
+		zeroing := isZeroingMove(board, child)
+		if zeroing {
+			childWDL, childResult := tablebase.search(*child, false)
+			if childResult == syzygyFail {
+				return 0, syzygyFail
+			}
+			dtz = -dtzBeforeZeroing(childWDL)
+		} else {
+			childDTZ, childResult := tablebase.probeDTZ(*child)
+			if childResult == syzygyFail {
+				return 0, syzygyFail
+			}
+			dtz = -childDTZ
+		}
+
+		if dtz == 1 && child.isChecked() && !child.hasPossibleBoardMoves() {
+			minDTZ = 1
+		}
+		if !zeroing {
+			dtz += signOf(dtz)
+		}
+		if dtz < minDTZ && signOf(dtz) == signOf(int(wdl)) {
+			minDTZ = dtz
+		}
+	}
+
+	if minDTZ == 0xFFFF {
+		return -1, syzygyOK
+	}
+	return minDTZ, syzygyOK
+}
+
+//materialCode names the pieces of one colour in table order, such as "KRP"
+func (boardState Board) materialCode(colour Colour) string {
+	code := ""
+	for _, sign := range syzygyPieceOrder {
+		for _, piece := range boardState.pieces {
+			if piece.colour == colour && piece.pieceType.sign == sign {
+				code += sign
+			}
+		}
+	}
+	return code
+}
+
+func (tablebase *SyzygyTablebase) probeTable(board Board, dtz bool, wdl WDL) (int, syzygyResult) {
+	if len(board.pieces) == 2 {
+		return int(WDLDraw), syzygyOK
+	}
+
+	whiteCode := board.materialCode(White)
+	blackCode := board.materialCode(Black)
+	name := whiteCode + "v" + blackCode
+	if !tablebase.names[name] {
+		name = blackCode + "v" + whiteCode
+	}
+	if !tablebase.names[name] {
+		return 0, syzygyFail
+	}
+
+	extension := ".rtbw"
+	if dtz {
+		extension = ".rtbz"
+	}
+	tablebase.lock.Lock()
+	table, ok := tablebase.tables[name+extension]
+	if !ok {
+		var err error
+		table, err = readSyzygyTable(tablebase.dir+name+extension, name, dtz)
+		if err != nil {
+			print(err.Error() + "\n")
+		}
+		tablebase.tables[name+extension] = table
+	}
+	tablebase.lock.Unlock()
+	if table == nil {
+		return 0, syzygyFail
+	}
+
+	return table.probe(board, whiteCode+"v"+blackCode != name, wdl)
+}
+
+func readSyzygyTable(path, name string, dtz bool) (*syzygyTable, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	magic := syzygyWDLMagic
+	if dtz {
+		magic = syzygyDTZMagic
+	}
+	if len(raw) < 6 || string(raw[:4]) != string(magic) {
+		return nil, fmt.Errorf("%s is not a syzygy table", path)
+	}
+
+	sides := strings.Split(name, "v")
+	table := &syzygyTable{
+		name:       name,
+		isDTZ:      dtz,
+		raw:        raw,
+		pieceCount: len(sides[0]) + len(sides[1]),
+		hasPawns:   strings.Contains(name, "P"),
+		symmetric:  sides[0] == sides[1],
+	}
+	for _, side := range sides {
+		for _, sign := range []string{"P", "N", "B", "R", "Q"} {
+			if strings.Count(side, sign) == 1 {
+				table.hasUniquePieces = true
+			}
+		}
+	}
+
+	//the leading colour is the one with fewer pawns, as this compresses better
+	whitePawns := strings.Count(sides[0], "P")
+	blackPawns := strings.Count(sides[1], "P")
+	if blackPawns == 0 || (whitePawns > 0 && blackPawns >= whitePawns) {
+		table.pawnCount = [2]int{whitePawns, blackPawns}
+	} else {
+		table.pawnCount = [2]int{blackPawns, whitePawns}
+	}
+
+	if (raw[4]&2 != 0) != table.hasPawns || (raw[4]&1 != 0) == table.symmetric {
+		return nil, fmt.Errorf("%s does not match its file name", path)
+	}
+
+	table.init()
+	return table, nil
+}
+
+func (table *syzygyTable) sides() int {
+	if !table.isDTZ && !table.symmetric {
+		return 2
+	}
+	return 1
+}
+
+func (table *syzygyTable) maxFile() int {
+	if table.hasPawns {
+		return 3
+	}
+	return 0
+}
+
+func (table *syzygyTable) get(stm int, file int) *syzygyPairsData {
+	if !table.hasPawns {
+		file = 0
+	}
+	return table.items[stm%table.sides()][file]
+}
+
+func (table *syzygyTable) le16(position int) int {
+	return int(binary.LittleEndian.Uint16(table.raw[position:]))
+}
+
+func (table *syzygyTable) init() {
+	raw := table.raw
+	data := 5
+	pp := table.hasPawns && table.pawnCount[1] > 0
+	ppBytes := 0
+	if pp {
+		ppBytes = 1
+	}
+
+	for file := 0; file <= table.maxFile(); file++ {
+		for i := 0; i < table.sides(); i++ {
+			table.items[i][file] = &syzygyPairsData{}
+		}
+
+		order := [2][2]int{{int(raw[data] & 0xF), 0xF}, {int(raw[data] >> 4), 0xF}}
+		if pp {
+			order[0][1] = int(raw[data+1] & 0xF)
+			order[1][1] = int(raw[data+1] >> 4)
+		}
+		data += 1 + ppBytes
+
+		for k := 0; k < table.pieceCount; k++ {
+			for i := 0; i < table.sides(); i++ {
+				if i == 0 {
+					table.items[i][file].pieces[k] = int(raw[data] & 0xF)
+				} else {
+					table.items[i][file].pieces[k] = int(raw[data] >> 4)
+				}
+			}
+			data++
+		}
+
+		for i := 0; i < table.sides(); i++ {
+			table.setGroups(table.items[i][file], order[i], file)
+		}
+	}
+
+	data += data & 1
+
+	for file := 0; file <= table.maxFile(); file++ {
+		for i := 0; i < table.sides(); i++ {
+			data = table.setSizes(table.items[i][file], data)
+		}
+	}
+
+	if table.isDTZ {
+		data = table.setDTZMap(data)
+	}
+
+	for file := 0; file <= table.maxFile(); file++ {
+		for i := 0; i < table.sides(); i++ {
+			pairs := table.items[i][file]
+			pairs.sparseIndex = data
+			data += int(pairs.sparseIndexSize) * 6
+		}
+	}
+
+	for file := 0; file <= table.maxFile(); file++ {
+		for i := 0; i < table.sides(); i++ {
+			pairs := table.items[i][file]
+			pairs.blockLength = data
+			data += int(pairs.blocksNum) * 2
+		}
+	}
+
+	for file := 0; file <= table.maxFile(); file++ {
+		for i := 0; i < table.sides(); i++ {
+			pairs := table.items[i][file]
+			data = (data + 0x3F) &^ 0x3F
+			pairs.data = data
+			data += int(pairs.numBlocks * pairs.sizeofBlock)
+		}
+	}
+}
+
+//setGroups splits the pieces into the groups they are encoded in and works out the index multiplier of each group
+func (table *syzygyTable) setGroups(pairs *syzygyPairsData, order [2]int, file int) {
+	n := 0
+	firstLen := 2
+	if table.hasPawns {
+		firstLen = 0
+	} else if table.hasUniquePieces {
+		firstLen = 3
+	}
+	pairs.groupLen[n] = 1
+
+	for i := 1; i < table.pieceCount; i++ {
+		firstLen--
+		if firstLen > 0 || pairs.pieces[i] == pairs.pieces[i-1] {
+			pairs.groupLen[n]++
+		} else {
+			n++
+			pairs.groupLen[n] = 1
+		}
+	}
+	n++
+	pairs.groupLen[n] = 0
+
+	pp := table.hasPawns && table.pawnCount[1] > 0
+	next := 1
+	freeSquares := 64 - pairs.groupLen[0]
+	if pp {
+		next = 2
+		freeSquares -= pairs.groupLen[1]
+	}
+	idx := uint64(1)
+
+	for k := 0; next < n || k == order[0] || k == order[1]; k++ {
+		if k == order[0] {
+			pairs.groupIdx[0] = idx
+			if table.hasPawns {
+				idx *= syzygyIndexes.leadPawnsSize[pairs.groupLen[0]][file]
+			} else if table.hasUniquePieces {
+				idx *= 31332
+			} else {
+				idx *= 462
+			}
+		} else if k == order[1] {
+			pairs.groupIdx[1] = idx
+			idx *= syzygyIndexes.binomial[pairs.groupLen[1]][48-pairs.groupLen[0]]
+		} else {
+			pairs.groupIdx[next] = idx
+			idx *= syzygyIndexes.binomial[pairs.groupLen[next]][freeSquares]
+			freeSquares -= pairs.groupLen[next]
+			next++
+		}
+	}
+	pairs.groupIdx[n] = idx
+}
+
+func (table *syzygyTable) btreeLeft(pairs *syzygyPairsData, sym int) int {
+	position := pairs.btree + 3*sym
+	return int(table.raw[position+1]&0xF)<<8 | int(table.raw[position])
+}
+
+func (table *syzygyTable) btreeRight(pairs *syzygyPairsData, sym int) int {
+	position := pairs.btree + 3*sym
+	return int(table.raw[position+2])<<4 | int(table.raw[position+1]>>4)
+}
+
+//setSymlen works out how many values each symbol expands to, less one. Symbols are pairs of earlier symbols
+func (table *syzygyTable) setSymlen(pairs *syzygyPairsData, sym int, visited []bool) int {
+	visited[sym] = true
+	right := table.btreeRight(pairs, sym)
+	if right == 0xFFF {
+		return 0
+	}
+	left := table.btreeLeft(pairs, sym)
+	if !visited[left] {
+		pairs.symlen[left] = table.setSymlen(pairs, left, visited)
+	}
+	if !visited[right] {
+		pairs.symlen[right] = table.setSymlen(pairs, right, visited)
+	}
+	return pairs.symlen[left] + pairs.symlen[right] + 1
+}
+
+//setSizes reads the Huffman code and pairing tree of a subtable
+func (table *syzygyTable) setSizes(pairs *syzygyPairsData, data int) int {
+	raw := table.raw
+	pairs.flags = raw[data]
+	data++
+
+	if pairs.flags&syzygyFlagSingleValue != 0 {
+		pairs.minSymLen = int(raw[data])
+		return data + 1
+	}
+
+	groups := 0
+	for pairs.groupLen[groups] != 0 {
+		groups++
+	}
+	tbSize := pairs.groupIdx[groups]
+
+	pairs.sizeofBlock = 1 << raw[data]
+	pairs.span = 1 << raw[data+1]
+	pairs.sparseIndexSize = (tbSize + pairs.span - 1) / pairs.span
+	padding := uint64(raw[data+2])
+	pairs.numBlocks = uint64(binary.LittleEndian.Uint32(raw[data+3:]))
+	pairs.blocksNum = pairs.numBlocks + padding
+	pairs.maxSymLen = int(raw[data+7])
+	pairs.minSymLen = int(raw[data+8])
+	data += 9
+	pairs.lowestSym = data
+
+	//base64 holds the lowest canonical Huffman code of each length, left aligned in 64 bits
+	pairs.base64 = make([]uint64, pairs.maxSymLen-pairs.minSymLen+1)
+	for i := len(pairs.base64) - 2; i >= 0; i-- {
+		pairs.base64[i] = (pairs.base64[i+1] + uint64(table.le16(pairs.lowestSym+2*i)) - uint64(table.le16(pairs.lowestSym+2*(i+1)))) / 2
+	}
+	for i := range pairs.base64 {
+		pairs.base64[i] <<= uint(64 - i - pairs.minSymLen)
+	}
+
+	data += len(pairs.base64) * 2
+	pairs.symlen = make([]int, table.le16(data))
+	data += 2
+	pairs.btree = data
+
+	visited := make([]bool, len(pairs.symlen))
+	for sym := range pairs.symlen {
+		if !visited[sym] {
+			pairs.symlen[sym] = table.setSymlen(pairs, sym, visited)
+		}
+	}
+
+	return data + len(pairs.symlen)*3 + len(pairs.symlen)&1
+}
+
+//setDTZMap reads the maps from stored DTZ values to real ones
+func (table *syzygyTable) setDTZMap(data int) int {
+	table.dtzMap = data
+	for file := 0; file <= table.maxFile(); file++ {
+		pairs := table.items[0][file]
+		if pairs.flags&syzygyFlagMapped == 0 {
+			continue
+		}
+		if pairs.flags&syzygyFlagWide != 0 {
+			data += data & 1
+			for i := 0; i < 4; i++ {
+				pairs.mapIdx[i] = (data-table.dtzMap)/2 + 1
+				data += 2*table.le16(data) + 2
+			}
+		} else {
+			for i := 0; i < 4; i++ {
+				pairs.mapIdx[i] = data - table.dtzMap + 1
+				data += int(table.raw[data]) + 1
+			}
+		}
+	}
+	return data + data&1
+}
+
+//decompress finds the value stored at idx in a subtable
+func (table *syzygyTable) decompress(pairs *syzygyPairsData, idx uint64) int {
+	if pairs.flags&syzygyFlagSingleValue != 0 {
+		return pairs.minSymLen
+	}
+	raw := table.raw
+
+	//the sparse index points to the block holding the middle value of every span
+	k := idx / pairs.span
+	block := int(binary.LittleEndian.Uint32(raw[pairs.sparseIndex+6*int(k):]))
+	offset := table.le16(pairs.sparseIndex + 6*int(k) + 4)
+	offset += int(idx%pairs.span) - int(pairs.span/2)
+
+	for offset < 0 {
+		block--
+		offset += table.le16(pairs.blockLength+2*block) + 1
+	}
+	for offset > table.le16(pairs.blockLength+2*block) {
+		offset -= table.le16(pairs.blockLength+2*block) + 1
+		block++
+	}
+
+	ptr := pairs.data + block*int(pairs.sizeofBlock)
+	buf64 := binary.BigEndian.Uint64(raw[ptr:])
+	ptr += 8
+	buf64Size := 64
+	var sym int
+
+	for {
+		length := 0
+		for buf64 < pairs.base64[length] {
+			length++
+		}
+		sym = int(uint16((buf64-pairs.base64[length])>>uint(64-length-pairs.minSymLen)) + uint16(table.le16(pairs.lowestSym+2*length)))
+
+		if offset < pairs.symlen[sym]+1 {
+			break
+		}
+
+		offset -= pairs.symlen[sym] + 1
+		length += pairs.minSymLen
+		buf64 <<= uint(length)
+		buf64Size -= length
+
+		if buf64Size <= 32 {
+			buf64Size += 32
+			buf64 |= uint64(binary.BigEndian.Uint32(raw[ptr:])) << uint(64-buf64Size)
+			ptr += 4
+		}
+	}
+
+	//expand the symbol down the pairing tree until the single value at offset is reached
+	for pairs.symlen[sym] != 0 {
+		left := table.btreeLeft(pairs, sym)
+		if offset < pairs.symlen[left]+1 {
+			sym = left
+		} else {
+			offset -= pairs.symlen[left] + 1
+			sym = table.btreeRight(pairs, sym)
+		}
+	}
+
+	return table.btreeLeft(pairs, sym)
+}
+
+func (table *syzygyTable) checkDTZSTM(stm int, file int) bool {
+	if !table.isDTZ {
+		return true
+	}
+	return int(table.get(stm, file).flags&syzygyFlagSTM) == stm || (table.symmetric && !table.hasPawns)
+}
+
+func (table *syzygyTable) mapScore(file int, value int, wdl WDL) int {
+	if !table.isDTZ {
+		return value - 2
+	}
+
+	wdlMap := []int{1, 3, 0, 2, 0}
+	pairs := table.get(0, file)
+	if pairs.flags&syzygyFlagMapped != 0 {
+		if pairs.flags&syzygyFlagWide != 0 {
+			value = table.le16(table.dtzMap + 2*(pairs.mapIdx[wdlMap[wdl+2]]+value))
+		} else {
+			value = int(table.raw[table.dtzMap+pairs.mapIdx[wdlMap[wdl+2]]+value])
+		}
+	}
+
+	//values are stored in moves rather than plies unless flagged otherwise
+	if (wdl == WDLWin && pairs.flags&syzygyFlagWinPlies == 0) || (wdl == WDLLoss && pairs.flags&syzygyFlagLossPlies == 0) ||
+		wdl == WDLCursedWin || wdl == WDLBlessedLoss {
+		value *= 2
+	}
+	return value + 1
+}
+
+func flipFile(square int) int {
+	return square ^ 7
+}
+
+func flipRank(square int) int {
+	return square ^ 56
+}
+
+//probe encodes the board as an index into the table and reads its value. Tables are stored with white as the
+//stronger side, so blackStronger boards are looked up with their colours swapped
+func (table *syzygyTable) probe(board Board, blackStronger bool, wdl WDL) (int, syzygyResult) {
+	tables := syzygyIndexes
+	squares := []int{}
+	pieces := []int{}
+
+	blackSymmetric := board.colourToMove == Black && table.symmetric
+	flipColour := 0
+	flipSquares := 0
+	stm := 0
+	if blackSymmetric || blackStronger {
+		flipColour = 8
+		flipSquares = 56
+		stm = 1
+	}
+	if board.colourToMove == Black {
+		stm ^= 1
+	}
+
+	occupied := []*Piece{}
+	for square := 0; square < 64; square++ {
+		if piece := board.squares[square&7][square>>3]; piece != nil {
+			occupied = append(occupied, piece)
+		}
+	}
+	pieceCode := func(piece *Piece) int {
+		code := syzygyPieceCodes[piece.pieceType.sign]
+		if piece.colour == Black {
+			code += 8
+		}
+		return code ^ flipColour
+	}
+	squareOf := func(piece *Piece) int {
+		return (piece.position.Y*8 + piece.position.X) ^ flipSquares
+	}
+
+	//the leading pawns come first, with the one with the highest mapPawns in front
+	leadPawnsCnt := 0
+	tbFile := 0
+	leadPawnCode := 0
+	if table.hasPawns {
+		leadPawnCode = table.get(0, 0).pieces[0]
+		for _, piece := range occupied {
+			if pieceCode(piece) == leadPawnCode {
+				squares = append(squares, squareOf(piece))
+				pieces = append(pieces, leadPawnCode)
+			}
+		}
+		leadPawnsCnt = len(squares)
+
+		lead := 0
+		for i := range squares {
+			if tables.mapPawns[squares[i]] > tables.mapPawns[squares[lead]] {
+				lead = i
+			}
+		}
+		squares[0], squares[lead] = squares[lead], squares[0]
+
+		tbFile = squares[0] & 7
+		if tbFile > 3 {
+			tbFile = 7 - tbFile
+		}
+	}
+
+	if !table.checkDTZSTM(stm, tbFile) {
+		return 0, syzygyChangeSTM
+	}
+
+	for _, piece := range occupied {
+		if table.hasPawns && pieceCode(piece) == leadPawnCode {
+			continue
+		}
+		squares = append(squares, squareOf(piece))
+		pieces = append(pieces, pieceCode(piece))
+	}
+	size := len(squares)
+	pairs := table.get(stm, tbFile)
+
+	//put the pieces in the order they are stored in
+	for i := leadPawnsCnt; i < size-1; i++ {
+		for j := i + 1; j < size; j++ {
+			if pairs.pieces[i] == pieces[j] {
+				pieces[i], pieces[j] = pieces[j], pieces[i]
+				squares[i], squares[j] = squares[j], squares[i]
+				break
+			}
+		}
+	}
+
+	if squares[0]&7 > 3 {
+		for i := range squares {
+			squares[i] = flipFile(squares[i])
+		}
+	}
+
+	var idx uint64
+	if table.hasPawns {
+		idx = tables.leadPawnIdx[leadPawnsCnt][squares[0]]
+		otherLeadPawns := squares[1:leadPawnsCnt]
+		sort.SliceStable(otherLeadPawns, func(i, j int) bool {
+			return tables.mapPawns[otherLeadPawns[i]] < tables.mapPawns[otherLeadPawns[j]]
+		})
+		for i := 1; i < leadPawnsCnt; i++ {
+			idx += tables.binomial[i][tables.mapPawns[squares[i]]]
+		}
+	} else {
+		if squares[0]>>3 > 3 {
+			for i := range squares {
+				squares[i] = flipRank(squares[i])
+			}
+		}
+
+		//the first leading piece off the a1-h8 diagonal must be below it
+		for i := 0; i < pairs.groupLen[0]; i++ {
+			if offA1H8(squares[i]) == 0 {
+				continue
+			}
+			if offA1H8(squares[i]) > 0 {
+				for j := i; j < size; j++ {
+					squares[j] = ((squares[j] >> 3) | (squares[j] << 3)) & 63
+				}
+			}
+			break
+		}
+
+		if table.hasUniquePieces {
+			adjust1 := 0
+			if squares[1] > squares[0] {
+				adjust1 = 1
+			}
+			adjust2 := 0
+			if squares[2] > squares[0] {
+				adjust2++
+			}
+			if squares[2] > squares[1] {
+				adjust2++
+			}
+
+			if offA1H8(squares[0]) != 0 {
+				idx = uint64((tables.mapA1D1D4[squares[0]]*63+(squares[1]-adjust1))*62 + squares[2] - adjust2)
+			} else if offA1H8(squares[1]) != 0 {
+				idx = uint64((6*63+(squares[0]>>3)*28+tables.mapB1H1H7[squares[1]])*62 + squares[2] - adjust2)
+			} else if offA1H8(squares[2]) != 0 {
+				idx = uint64(6*63*62 + 4*28*62 + (squares[0]>>3)*7*28 + ((squares[1]>>3)-adjust1)*28 + tables.mapB1H1H7[squares[2]])
+			} else {
+				idx = uint64(6*63*62 + 4*28*62 + 4*7*28 + (squares[0]>>3)*7*6 + ((squares[1]>>3)-adjust1)*6 + (squares[2] >> 3) - adjust2)
+			}
+		} else {
+			idx = uint64(tables.mapKK[tables.mapA1D1D4[squares[0]]][squares[1]])
+		}
+	}
+
+	//encode the remaining groups, each ascending by square
+	idx *= pairs.groupIdx[0]
+	groupStart := pairs.groupLen[0]
+	remainingPawns := table.hasPawns && table.pawnCount[1] > 0
+	for next := 1; pairs.groupLen[next] != 0; next++ {
+		group := squares[groupStart : groupStart+pairs.groupLen[next]]
+		sort.Ints(group)
+		n := uint64(0)
+		for i, square := range group {
+			adjust := 0
+			for _, earlier := range squares[:groupStart] {
+				if square > earlier {
+					adjust++
+				}
+			}
+			pawnOffset := 0
+			if remainingPawns {
+				pawnOffset = 8
+			}
+			n += tables.binomial[i+1][square-adjust-pawnOffset]
+		}
+		remainingPawns = false
+		idx += n * pairs.groupIdx[next]
+		groupStart += pairs.groupLen[next]
+	}
+
+	return table.mapScore(tbFile, table.decompress(pairs, idx), wdl), syzygyOK
+}
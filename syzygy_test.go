@@ -0,0 +1,140 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestSyzygyIndexTables(t *testing.T) {
+	maxCode := 0
+	for _, row := range syzygyIndexes.mapKK {
+		for _, code := range row {
+			if code > maxCode {
+				maxCode = code
+			}
+		}
+	}
+	if maxCode != 461 {
+		t.Errorf("expected 462 king placements but the highest code is %d", maxCode)
+	}
+	if syzygyIndexes.mapPawns[8] != 47 || syzygyIndexes.mapPawns[15] != 46 {
+		t.Errorf("a2 and h2 should be the first pawn squares")
+	}
+	if syzygyIndexes.binomial[2][5] != 10 {
+		t.Errorf("expected 5 choose 2 to be 10 but got %d", syzygyIndexes.binomial[2][5])
+	}
+}
+
+//writeSingleValueKQvK writes a KQvK WDL table where every position is a win with white to move and a loss with black
+//to move. Single value subtables skip the Huffman data but exercise the header, naming and colour flipping
+func writeSingleValueKQvK(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "syzygy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := append([]byte{}, syzygyWDLMagic...)
+	table = append(table, 0x01, 0x00, 0x66, 0x55, 0xEE, 0x00)
+	table = append(table, syzygyFlagSingleValue, 4, syzygyFlagSingleValue, 0)
+	if err := ioutil.WriteFile(dir+"/KQvK.rtbw", table, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir + "/"
+}
+
+func TestSyzygyProbeWDL(t *testing.T) {
+	dir := writeSingleValueKQvK(t)
+	defer os.RemoveAll(dir)
+	tablebase := loadSyzygy(dir)
+
+	positions := map[string]WDL{
+		"4k3/8/8/8/8/8/8/Q3K3 w - - 0 1": WDLWin,
+		"4k3/8/8/8/8/8/8/Q3K3 b - - 0 1": WDLLoss,
+		"q3k3/8/8/8/8/8/8/4K3 b - - 0 1": WDLWin,
+		"q3k3/8/8/8/8/8/8/4K3 w - - 0 1": WDLLoss,
+		//the queen can be taken
+		"7K/8/8/8/8/8/1Q6/k7 b - - 0 1": WDLDraw,
+	}
+	for fen, expected := range positions {
+		state, err := BoardFromFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wdl, ok := tablebase.ProbeWDL(state)
+		if !ok || wdl != expected {
+			t.Errorf("%s: expected %d but got %d (found %t)", fen, expected, wdl, ok)
+		}
+	}
+
+	state, _ := BoardFromFEN("4k3/8/8/8/8/8/8/R3K3 w - - 0 1")
+	if _, ok := tablebase.ProbeWDL(state); ok {
+		t.Errorf("probed a position without a table")
+	}
+	if _, ok := (*SyzygyTablebase)(nil).ProbeWDL(state); ok {
+		t.Errorf("probed without a tablebase")
+	}
+}
+
+func TestSyzygyAdjudication(t *testing.T) {
+	dir := writeSingleValueKQvK(t)
+	defer os.RemoveAll(dir)
+	tablebase := loadSyzygy(dir)
+
+	state, _ := BoardFromFEN("q3k3/8/8/8/8/8/8/4K3 w - - 0 1")
+	if result, ok := adjudicate(state, tablebase); !ok || result != BlackWon {
+		t.Errorf("expected black to be adjudicated the winner but got %v", result)
+	}
+	state, _ = BoardFromFEN("4k3/8/8/8/8/8/8/R3K3 w - - 0 1")
+	if _, ok := adjudicate(state, tablebase); ok {
+		t.Errorf("adjudicated a position without a table")
+	}
+}
+
+//TestSyzygyFiles checks the official tables, which are not in the repository. Copy KQvK, KRvK, KPvK, KBvK, KNvK and
+//KBNvK (.rtbw and .rtbz) from https://tablebase.lichess.ovh/tables/standard/3-4-5/ into testdata/syzygy to run it.
+//Pawnless tables count mate as the zeroing move, so their dtz is the distance to mate in plies
+func TestSyzygyFiles(t *testing.T) {
+	tablebase := loadSyzygy("./testdata/syzygy/")
+	if tablebase.maxPieces == 0 {
+		t.Skip("no syzygy tables in testdata/syzygy")
+	}
+	for _, name := range []string{"KQvK", "KRvK", "KPvK", "KBvK", "KNvK", "KBNvK"} {
+		if !tablebase.names[name] {
+			t.Fatalf("testdata/syzygy is missing %s", name)
+		}
+	}
+
+	positions := []struct {
+		fen string
+		wdl WDL
+		dtz int
+	}{
+		{"k7/8/1K6/8/8/8/7Q/8 w - - 0 1", WDLWin, 1},
+		//the only move is Kb8, after which Qh8 is mate
+		{"k7/8/1K6/8/8/8/8/7Q b - - 0 1", WDLLoss, -2},
+		{"4k3/8/8/8/8/8/8/Q3K3 w - - 0 1", WDLWin, 13},
+		{"q3k3/8/8/8/8/8/8/4K3 w - - 0 1", WDLLoss, -16},
+		{"4k3/8/8/8/8/8/8/R3K3 b - - 0 1", WDLLoss, -28},
+		//the queen can be taken
+		{"8/8/8/8/8/8/1Qk5/4K3 b - - 0 1", WDLDraw, 0},
+		{"4k3/8/8/8/8/8/P7/K7 w - - 0 1", WDLDraw, 0},
+		{"7k/P7/8/8/8/8/8/K7 w - - 0 1", WDLWin, 1},
+		//the king has to step aside before the pawn can move
+		{"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1", WDLWin, 3},
+		{"8/8/8/8/8/8/4p3/4K2k w - - 0 1", WDLDraw, 0},
+		{"7k/4N3/3B2K1/8/8/8/8/8 w - - 0 1", WDLWin, 1},
+		//the knight can be taken
+		{"8/8/8/8/8/2N5/1k6/4KB2 b - - 0 1", WDLDraw, 0},
+	}
+	for _, position := range positions {
+		state, _ := BoardFromFEN(position.fen)
+		wdl, ok := tablebase.ProbeWDL(state)
+		if !ok || wdl != position.wdl {
+			t.Errorf("%s: expected %d but got %d (found %t)", position.fen, position.wdl, wdl, ok)
+		}
+		dtz, ok := tablebase.ProbeDTZ(state)
+		if !ok || dtz != position.dtz {
+			t.Errorf("%s: expected a dtz of %d but got %d (found %t)", position.fen, position.dtz, dtz, ok)
+		}
+	}
+}
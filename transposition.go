@@ -0,0 +1,141 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"unsafe"
+)
+
+//Bound says how a stored value relates to the true value of a position
+type Bound uint8
+
+const (
+	//ExactBound values are the true value
+	ExactBound Bound = iota + 1
+	//LowerBound values failed high, so the true value is at least the value
+	LowerBound
+	//UpperBound values failed low, so the true value is at most the value
+	UpperBound
+)
+
+//transpositionStripes is how many locks the table's entries are shared between, so parallel searches rarely wait on
+//each other
+const transpositionStripes = 256
+
+//maxPocketCount is the most pieces of one type a Crazyhouse pocket is hashed with
+const maxPocketCount = 16
+
+//SearchKeys are the random numbers the search hashes positions with. Boards are hashed the Polyglot way, and
+//Crazyhouse pockets have a number for each piece type and count
+type SearchKeys struct {
+	board  PolyglotKeys
+	pocket map[Colour]map[string][maxPocketCount + 1]uint64
+}
+
+//searchKeys are made from a fixed seed so searches are repeatable
+var searchKeys = newSearchKeys(rand.New(rand.NewSource(0x5EA4C4)))
+
+func newSearchKeys(random *rand.Rand) *SearchKeys {
+	keys := &SearchKeys{PolyglotKeys{}, map[Colour]map[string][maxPocketCount + 1]uint64{}}
+	for i := range keys.board {
+		keys.board[i] = random.Uint64()
+	}
+	for _, colour := range []Colour{White, Black} {
+		keys.pocket[colour] = map[string][maxPocketCount + 1]uint64{}
+		for _, sign := range []string{"P", "N", "B", "R", "Q"} {
+			counts := [maxPocketCount + 1]uint64{}
+			for count := 1; count <= maxPocketCount; count++ {
+				counts[count] = random.Uint64()
+			}
+			keys.pocket[colour][sign] = counts
+		}
+	}
+	return keys
+}
+
+func (keys *SearchKeys) key(board Board) uint64 {
+	key := keys.board.key(board)
+	pockets := map[Colour]map[string]int{White: board.whitePocket, Black: board.blackPocket}
+	for colour, pocket := range pockets {
+		for sign, count := range pocket {
+			if count > 0 && count <= maxPocketCount {
+				key ^= keys.pocket[colour][sign][count]
+			}
+		}
+	}
+	return key
+}
+
+//TranspositionEntry is what the search learnt about a position. move is the best move in Polyglot's format, and mates
+//in value are counted from the position rather than the root
+type TranspositionEntry struct {
+	key   uint64
+	value Score
+	move  uint16
+	depth int16
+	bound Bound
+	age   uint8
+}
+
+//TranspositionTable remembers searched positions, so positions reached by transposition are not searched again and
+//the best move from an earlier iteration is searched first
+type TranspositionTable struct {
+	entries []TranspositionEntry
+	mask    uint64
+	//age counts searches, so entries from earlier moves of a game are replaced first
+	age   uint8
+	locks [transpositionStripes]sync.Mutex
+}
+
+//newTranspositionTable makes the largest table with a power of two entries that fits in megabytes. There is no table
+//when megabytes is 0
+func newTranspositionTable(megabytes int) *TranspositionTable {
+	if megabytes <= 0 {
+		return nil
+	}
+	size := int(unsafe.Sizeof(TranspositionEntry{}))
+	count := 1
+	for 2*count*size <= megabytes<<20 {
+		count *= 2
+	}
+	return &TranspositionTable{entries: make([]TranspositionEntry, count), mask: uint64(count - 1)}
+}
+
+//newSearch ages the entries already in the table
+func (table *TranspositionTable) newSearch() {
+	if table != nil {
+		table.age++
+	}
+}
+
+func (table *TranspositionTable) probe(key uint64) (TranspositionEntry, bool) {
+	if table == nil {
+		return TranspositionEntry{}, false
+	}
+	index := key & table.mask
+	lock := &table.locks[index%transpositionStripes]
+	lock.Lock()
+	entry := table.entries[index]
+	lock.Unlock()
+	return entry, entry.bound != 0 && entry.key == key
+}
+
+//store keeps an entry unless its slot holds a deeper search of another position from the current search
+func (table *TranspositionTable) store(key uint64, depth int, bound Bound, value Score, move uint16) {
+	if table == nil {
+		return
+	}
+	index := key & table.mask
+	lock := &table.locks[index%transpositionStripes]
+	lock.Lock()
+	defer lock.Unlock()
+	slot := &table.entries[index]
+	if slot.bound != 0 && slot.key != key && slot.age == table.age && int(slot.depth) > depth {
+		return
+	}
+	if slot.key == key && move == 0 {
+		//keep the best move from an earlier search of the same position
+		move = slot.move
+	}
+	*slot = TranspositionEntry{key, value, move, int16(depth), bound, table.age}
+}
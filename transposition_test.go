@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestTranspositionTableSize(t *testing.T) {
+	if newTranspositionTable(0) != nil {
+		t.Errorf("made a table with no memory")
+	}
+	table := newTranspositionTable(1)
+	size := len(table.entries) * int(unsafe.Sizeof(TranspositionEntry{}))
+	if size > 1<<20 || 2*size <= 1<<20 || uint64(len(table.entries)-1) != table.mask {
+		t.Errorf("%d entries do not fill a megabyte with a power of two", len(table.entries))
+	}
+}
+
+func TestTranspositionTableReplacement(t *testing.T) {
+	table := newTranspositionTable(1)
+	key := uint64(12345)
+	other := key + uint64(len(table.entries))
+
+	table.store(key, 5, ExactBound, 1.5, 42)
+	if entry, ok := table.probe(key); !ok || entry.value != 1.5 || entry.depth != 5 || entry.move != 42 {
+		t.Fatalf("expected the stored entry but got %+v", entry)
+	}
+	if _, ok := table.probe(other); ok {
+		t.Errorf("found a position that was never stored")
+	}
+
+	table.store(other, 3, LowerBound, 2, 7)
+	if entry, _ := table.probe(key); entry.depth != 5 {
+		t.Errorf("a shallower search replaced a deeper one")
+	}
+	table.store(key, 2, UpperBound, 0.5, 0)
+	if entry, _ := table.probe(key); entry.depth != 2 || entry.move != 42 {
+		t.Errorf("expected a new search of the same position to replace it but keep its move, got %+v", entry)
+	}
+
+	table.store(key, 9, ExactBound, 1, 1)
+	table.newSearch()
+	table.store(other, 1, ExactBound, 3, 2)
+	if entry, ok := table.probe(other); !ok || entry.value != 3 {
+		t.Errorf("an entry from an earlier search was not replaced")
+	}
+}
+
+func TestSearchKeys(t *testing.T) {
+	first := playPolyglotMoves(t, NewBoard(), "e2e4", "e7e5", "g1f3")
+	second := playPolyglotMoves(t, NewBoard(), "g1f3", "e7e5", "e2e4")
+	if searchKeys.key(first) != searchKeys.key(second) {
+		t.Errorf("transposed positions have different keys")
+	}
+
+	pocket, _ := BoardFromFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[N] w KQkq - 0 1")
+	emptyPocket, _ := BoardFromFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1")
+	if searchKeys.key(pocket) == searchKeys.key(emptyPocket) {
+		t.Errorf("a knight in the pocket did not change the key")
+	}
+}
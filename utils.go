@@ -1,7 +1,6 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"sort"
 	"strconv"
@@ -26,14 +25,19 @@ func (vect1 Vector) mult(c int) Vector {
 	return Vector{vect1.X * c, vect1.Y * c}
 }
 
+//mirror reflects a square between the first and eighth ranks
+func (vect1 Vector) mirror() Vector {
+	return Vector{vect1.X, 7 - vect1.Y}
+}
+
 func (vect1 Vector) toString() string {
 	return fmt.Sprintf("(%d, %d)", vect1.X, vect1.Y)
 }
 
 func fromString(vect string) Vector {
 	nums := strings.Split(vect[1:len(vect)-1], ",")
-	x, _ := strconv.Atoi(nums[0])
-	y, _ := strconv.Atoi(nums[1])
+	x, _ := strconv.Atoi(strings.TrimSpace(nums[0]))
+	y, _ := strconv.Atoi(strings.TrimSpace(nums[1]))
 	return Vector{X: x, Y: y}
 }
 
@@ -45,9 +49,6 @@ func geometricAbs(value float64) float64 {
 	if value >= 1 {
 		return value
 	}
-	if value <= 0 {
-		errors.New("cannot get geometric absolute value of negative number")
-	}
 	return 1 / value
 }
 